@@ -2,6 +2,7 @@ package AST
 
 import (
 	"fmt"
+	"math/big"
 	"strings"
 )
 
@@ -19,30 +20,45 @@ type (
 	Expr interface {
 		AST
 		IsComputable() bool // if the expression is evaluatable at compile time
+		ResultWidth() int   // width of the result, set by the semantic pass
+		SetResultWidth(width int)
 		exprNode()
 		String() string
 	}
 
+	// Type information shared by every expression
+	Typed struct {
+		width int
+	}
+
 	// An Expression with Syntax errors
 	BadExpr struct {
+		Typed
 		Pos [2]int
 	}
 
 	// Represents an identifier used in computation
 	Ident struct {
+		Typed
 		Pos  [2]int
 		Name string
+		Obj  *Object // What the name refers to, set by the semantic pass
 	}
 
 	// Represents a 'literal' or plain text value
 	Literal struct {
+		Typed
 		Pos   [2]int
-		Type  string // to be switched to enum
-		Value string
+		Kind  LiteralKind
+		Text  string // As written in the source
+		Value *big.Int
+		Width int      // Explicit width, 0 if unsized
+		Mask  *big.Int // Bits written as ?, which match anything. Only used by the values of a switch
 	}
 
 	// Expression contained within parens (nested)
 	ParenExpr struct {
+		Typed
 		StartPos [2]int
 		EndPos   [2]int
 		X        Expr // Inner Expression
@@ -50,40 +66,114 @@ type (
 
 	// Represents a function call
 	CallExpr struct {
+		Typed
 		Pos  [2]int
 		Fn   string // Function identifier
 		Args []Expr //List of function arguments
 	}
 
+	// Represents an operation applied to a single value
+	UnaryExpr struct {
+		Typed
+		Pos [2]int
+		Op  Operation
+		X   Expr
+	}
+
 	// Represents a math calculation
 	MathExpr struct {
+		Typed
 		Pos [2]int
 		LHS Expr
 		RHS Expr
 		Op  Operation
 	}
+
+	// A single bit of a signal, X[Index]
+	IndexExpr struct {
+		Typed
+		Pos   [2]int
+		X     Expr
+		Index Expr
+	}
+
+	// A range of bits of a signal, X[Left:Right], X[Left +: Right] or X[Left -: Right]
+	SliceExpr struct {
+		Typed
+		Pos   [2]int
+		X     Expr
+		Kind  SliceKind
+		Left  Expr // Highest bit, or the start bit for the +: and -: forms
+		Right Expr // Lowest bit, or the width for the +: and -: forms
+	}
+
+	// Values joined together, the first being the most significant: {A, B}
+	ConcatExpr struct {
+		Typed
+		StartPos [2]int
+		EndPos   [2]int
+		Parts    []Expr
+	}
+
+	// A value repeated Count times: {Count{X}}
+	ReplicateExpr struct {
+		Typed
+		StartPos [2]int
+		EndPos   [2]int
+		Count    Expr
+		X        Expr
+	}
 )
 
-func (x *BadExpr) IsComputable() bool   { return false }
-func (x *Ident) IsComputable() bool     { return false }
-func (x *Literal) IsComputable() bool   { return true }
-func (x *ParenExpr) IsComputable() bool { return x.X.IsComputable() }
-func (x *CallExpr) IsComputable() bool  { return false }
-func (x *MathExpr) IsComputable() bool  { return false }
-
-func (x *BadExpr) GetPos() [2]int   { return x.Pos }
-func (x *Ident) GetPos() [2]int     { return x.Pos }
-func (x *Literal) GetPos() [2]int   { return x.Pos }
-func (x *ParenExpr) GetPos() [2]int { return x.StartPos }
-func (x *CallExpr) GetPos() [2]int  { return x.Pos }
-func (x *MathExpr) GetPos() [2]int  { return x.Pos }
-
-func (*BadExpr) exprNode()   {}
-func (*Ident) exprNode()     {}
-func (*Literal) exprNode()   {}
-func (*ParenExpr) exprNode() {}
-func (*CallExpr) exprNode()  {}
-func (*MathExpr) exprNode()  {}
+func (t *Typed) ResultWidth() int         { return t.width }
+func (t *Typed) SetResultWidth(width int) { t.width = width }
+
+func (x *BadExpr) IsComputable() bool       { return false }
+func (x *Ident) IsComputable() bool         { return x.Obj != nil && x.Obj.Value() != nil }
+func (x *Literal) IsComputable() bool       { return true }
+func (x *ParenExpr) IsComputable() bool     { return x.X.IsComputable() }
+func (x *CallExpr) IsComputable() bool      { return callComputable(x) }
+func (x *UnaryExpr) IsComputable() bool     { return unaryComputable(x) }
+func (x *MathExpr) IsComputable() bool      { return x.LHS.IsComputable() && x.RHS.IsComputable() }
+func (x *IndexExpr) IsComputable() bool     { return false }
+func (x *SliceExpr) IsComputable() bool     { return false }
+func (x *ConcatExpr) IsComputable() bool    { return false }
+func (x *ReplicateExpr) IsComputable() bool { return false }
+
+// Reduction and and bitwise not depend on the width of the operand
+func unaryComputable(x *UnaryExpr) bool {
+	switch x.Op {
+	case ReduceAnd:
+		return false
+	case BitNot:
+		return SizedWidth(x.X) > 0 && x.X.IsComputable()
+	}
+	return x.X.IsComputable()
+}
+
+func (x *BadExpr) GetPos() [2]int       { return x.Pos }
+func (x *Ident) GetPos() [2]int         { return x.Pos }
+func (x *Literal) GetPos() [2]int       { return x.Pos }
+func (x *ParenExpr) GetPos() [2]int     { return x.StartPos }
+func (x *CallExpr) GetPos() [2]int      { return x.Pos }
+func (x *UnaryExpr) GetPos() [2]int     { return x.Pos }
+func (x *MathExpr) GetPos() [2]int      { return x.Pos }
+func (x *IndexExpr) GetPos() [2]int     { return x.Pos }
+func (x *SliceExpr) GetPos() [2]int     { return x.Pos }
+func (x *ConcatExpr) GetPos() [2]int    { return x.StartPos }
+func (x *ReplicateExpr) GetPos() [2]int { return x.StartPos }
+
+func (*BadExpr) exprNode()       {}
+func (*Ident) exprNode()         {}
+func (*Literal) exprNode()       {}
+func (*ParenExpr) exprNode()     {}
+func (*CallExpr) exprNode()      {}
+func (*UnaryExpr) exprNode()     {}
+func (*MathExpr) exprNode()      {}
+func (*IndexExpr) exprNode()     {}
+func (*SliceExpr) exprNode()     {}
+func (*ConcatExpr) exprNode()    {}
+func (*ReplicateExpr) exprNode() {}
 
 func (s *BadExpr) String() string { return "BAD EXPRESSION" }
 func (x Ident) String() string {
@@ -91,7 +181,25 @@ func (x Ident) String() string {
 }
 
 func (x Literal) String() string {
-	return x.Value
+	return x.Text
+}
+
+//go:generate stringer -type=LiteralKind
+type LiteralKind int // The base a literal was written in
+
+const (
+	Decimal LiteralKind = iota
+	Hex
+	Binary
+	Octal
+)
+
+func (x ParenExpr) String() string {
+	return "(" + x.X.String() + ")"
+}
+
+func (x UnaryExpr) String() string {
+	return "(" + x.Op.String() + " " + x.X.String() + ")"
 }
 
 func (x MathExpr) String() string {
@@ -104,32 +212,143 @@ func (x MathExpr) String() string {
 	return str
 }
 
+func (x IndexExpr) String() string {
+	return x.X.String() + "[" + x.Index.String() + "]"
+}
+
+func (x SliceExpr) String() string {
+	sep := ":"
+	switch x.Kind {
+	case SliceUp:
+		sep = " +: "
+	case SliceDown:
+		sep = " -: "
+	}
+	return x.X.String() + "[" + x.Left.String() + sep + x.Right.String() + "]"
+}
+
+func (x ConcatExpr) String() string {
+	var parts []string
+	for _, part := range x.Parts {
+		parts = append(parts, part.String())
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func (x CallExpr) String() string {
+	var args []string
+	for _, arg := range x.Args {
+		args = append(args, arg.String())
+	}
+	return x.Fn + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (x ReplicateExpr) String() string {
+	return "{" + x.Count.String() + x.X.String() + "}"
+}
+
+//go:generate stringer -type=SliceKind
+type SliceKind int
+
+const (
+	SliceRange SliceKind = iota // [Hi:Lo]
+	SliceUp                     // [Start +: Width], counting up from Start
+	SliceDown                   // [Start -: Width], counting down from Start
+)
+
 //go:generate stringer -type=Operation
 type Operation int
 
 const (
 	Asmt Operation = iota
 	AsmtReg
-	LShift
-	RShift
+	LShift      // <<  logical
+	RShift      // >>  logical
+	ArithLShift // <<< arithmetic
+	ArithRShift // >>> arithmetic, sign extends
 	Add
 	Sub
 	Multi
 	Div
-	Bracket
+	Mod
 	Equals
+	NotEquals
+	Less
+	LessEq
+	Greater
+	GreaterEq
+	BitAnd
+	BitOr
+	BitXor
+	LogicAnd
+	LogicOr
+	Negate    // unary -
+	Not       // unary !, logical
+	BitNot    // unary ~
+	ReduceAnd // unary &, and of every bit
+	ReduceOr  // unary |
+	ReduceXor // unary ^
 )
 
+// Higher precedence operations bind more tightly, following verilog:
+//
+//	 0  = <-               (statement level only)
+//	 1  ||
+//	 2  &&
+//	 3  |
+//	 4  ^
+//	 5  &
+//	 6  == !=
+//	 7  < <= > >=
+//	 8  << >> <<< >>>
+//	 9  + -
+//	10  * / %
+//	11  - ! ~ & | ^        (unary)
 var Precedence = map[Operation]int{
-	Asmt:    0,
-	Equals:  1,
-	LShift:  2,
-	RShift:  2,
-	Add:     3,
-	Sub:     3,
-	Multi:   4,
-	Div:     4,
-	Bracket: 5,
+	Asmt:        0,
+	AsmtReg:     0,
+	LogicOr:     1,
+	LogicAnd:    2,
+	BitOr:       3,
+	BitXor:      4,
+	BitAnd:      5,
+	Equals:      6,
+	NotEquals:   6,
+	Less:        7,
+	LessEq:      7,
+	Greater:     7,
+	GreaterEq:   7,
+	LShift:      8,
+	RShift:      8,
+	ArithLShift: 8,
+	ArithRShift: 8,
+	Add:         9,
+	Sub:         9,
+	Multi:       10,
+	Div:         10,
+	Mod:         10,
+	Negate:      11,
+	Not:         11,
+	BitNot:      11,
+	ReduceAnd:   11,
+	ReduceOr:    11,
+	ReduceXor:   11,
+}
+
+// Lowest precedence of an operation within an expression
+const MinPrecedence = 1
+
+type Assoc int
+
+const (
+	LeftAssoc Assoc = iota
+	RightAssoc
+)
+
+// Operations are left associative unless listed here
+var Associativity = map[Operation]Assoc{
+	Asmt:    RightAssoc,
+	AsmtReg: RightAssoc,
 }
 
 /* --- Statements --- */
@@ -171,8 +390,14 @@ type (
 	SequenceStmt struct {
 		StartPos [2]int
 		EndPos   [2]int
-		Clk      string
+		Clk      ClockDecl
+		Start    Expr // Condition to start on, nil if it runs from reset
+		Restart  Restart
+		Busy     *Ident // Wire set while it is running, may be nil
+		Done     *Ident // Wire set on the clock it finishes, may be nil
 		Inner    Stmt
+		Abort    Expr // Condition to abandon the steps of Inner on, may be nil
+		Cleanup  Stmt // Steps run after an abort, may be nil
 	}
 
 	// a function return
@@ -196,14 +421,41 @@ type (
 		Else Stmt
 	}
 
-	// a looped block
+	// a looped block, either while Cond { ... } or repeat Count { ... }
 	LoopStmt struct {
-		Pos  [2]int
-		Cond Expr
-		Body Stmt
+		Pos   [2]int
+		Cond  Expr // Checked before each pass of a while loop, nil for a repeat loop
+		Count Expr // Number of passes of a repeat loop, nil for a while loop
+		Body  Stmt
+	}
+
+	// Holds a sequence until a condition is true, or for a number of clocks
+	WaitStmt struct {
+		Pos    [2]int
+		X      Expr
+		Cycles bool // X is a constant number of clocks rather than a condition, set by the semantic pass
+	}
+
+	// Runs the first arm with a value matching the selector
+	SwitchStmt struct {
+		Pos   [2]int
+		X     Expr
+		Cases []CaseClause
+
+		Complete    bool // Every value of the selector has an arm, set by the semantic pass
+		Overlapping bool // Some value matches more than one arm, so the order of the arms matters
 	}
 )
 
+// An arm of a switch, Values: Body
+type CaseClause struct {
+	Pos    [2]int
+	Values []Expr // Empty for the default arm
+	Body   Stmt   // Always a block, a single statement is placed in one
+}
+
+func (c CaseClause) IsDefault() bool { return len(c.Values) == 0 }
+
 func (s *BadStmt) GetPos() [2]int      { return s.Pos }
 func (s *DeclStmt) GetPos() [2]int     { return s.Pos }
 func (s *ExprStmt) GetPos() [2]int     { return s.Pos }
@@ -213,6 +465,8 @@ func (s *ReturnStmt) GetPos() [2]int   { return s.Pos }
 func (s *BlockStmt) GetPos() [2]int    { return s.StartPos }
 func (s *IfStmt) GetPos() [2]int       { return s.Pos }
 func (s *LoopStmt) GetPos() [2]int     { return s.Pos }
+func (s *SwitchStmt) GetPos() [2]int   { return s.Pos }
+func (s *WaitStmt) GetPos() [2]int     { return s.Pos }
 
 func (*BadStmt) stmtNode()      {}
 func (*DeclStmt) stmtNode()     {}
@@ -223,6 +477,8 @@ func (*ReturnStmt) stmtNode()   {}
 func (*BlockStmt) stmtNode()    {}
 func (*IfStmt) stmtNode()       {}
 func (*LoopStmt) stmtNode()     {}
+func (*SwitchStmt) stmtNode()   {}
+func (*WaitStmt) stmtNode()     {}
 
 func Indent(level int) string {
 	return strings.Repeat("  ", level)
@@ -233,6 +489,9 @@ func (s *DeclStmt) String(indent int) string {
 	var str string
 
 	str += Indent(indent)
+	if _, ok := s.Decl.(*SignalDecl); ok {
+		str += "sig "
+	}
 	str += fmt.Sprint(s.Decl)
 	return str
 }
@@ -264,6 +523,80 @@ func (s *IfStmt) String(indent int) string {
 	return str
 }
 
+func (s *LoopStmt) String(indent int) string {
+	var str string
+	str += Indent(indent)
+	if s.Cond != nil {
+		str += "while " + s.Cond.String() + "\n"
+	} else {
+		str += "repeat " + s.Count.String() + "\n"
+	}
+
+	str += s.Body.String(indent + 1)
+
+	return str
+}
+
+func (s *WaitStmt) String(indent int) string {
+	return Indent(indent) + "wait " + s.X.String()
+}
+
+func (s *SwitchStmt) String(indent int) string {
+	var str string
+	str += Indent(indent)
+	str += "switch " + s.X.String() + "\n"
+
+	for _, arm := range s.Cases {
+		str += Indent(indent + 1)
+		if arm.IsDefault() {
+			str += "default"
+		}
+		for i, value := range arm.Values {
+			if i > 0 {
+				str += ", "
+			}
+			str += value.String()
+		}
+		str += ":\n" + arm.Body.String(indent+2)
+	}
+
+	return str
+}
+
+func (s *SequenceStmt) String(indent int) string {
+	var str string
+	str += Indent(indent)
+	str += "@"
+	if s.Clk.Neg {
+		str += "!"
+	}
+	str += s.Clk.Name.Name
+	if s.Start != nil {
+		str += " when " + s.Start.String()
+	}
+	if s.Restart != Rearm {
+		str += " " + strings.ToLower(s.Restart.String())
+	}
+	if s.Busy != nil {
+		str += " busy " + s.Busy.Name
+	}
+	if s.Done != nil {
+		str += " done " + s.Done.Name
+	}
+	str += "\n"
+
+	str += s.Inner.String(indent + 1)
+
+	if s.Abort != nil {
+		str += Indent(indent) + "abort when " + s.Abort.String() + "\n"
+		if s.Cleanup != nil {
+			str += s.Cleanup.String(indent + 1)
+		}
+	}
+
+	return str
+}
+
 func (s BlockStmt) String(indent int) string {
 	var str string
 
@@ -283,10 +616,12 @@ type (
 		declNode()
 	}
 
+	// A named constant, const [Width] Name = Value
 	ValueDecl struct {
-		Name  Ident
-		Type  string // FIXME
-		Value Expr
+		Name      Ident
+		WidthExpr Expr // Width as written, nil if it is unsized
+		Width     int  // Evaluated from WidthExpr by the semantic pass
+		Value     Expr
 	}
 
 	ClockDecl struct {
@@ -295,9 +630,12 @@ type (
 	}
 
 	SignalDecl struct {
-		Name  Ident
-		Width int
-		Clock *ClockDecl
+		Name      Ident
+		WidthExpr Expr // Width as written, nil if it is to be inferred
+		Width     int  // Evaluated from WidthExpr by the semantic pass
+		Clock     *ClockDecl
+		Init      Expr // Initial value of a register, may be nil
+		Latch     bool // Holds its value on paths that don't assign it
 	}
 
 	ParamDecl struct { //Extends SignalDecl
@@ -305,13 +643,37 @@ type (
 		Dir ParamDir
 	}
 
+	// A module used within another, Module Name(Port: Value, ...)
+	InstanceDecl struct {
+		Module Ident
+		Name   Ident
+		Conns  []PortConn
+		Def    *ModuleDecl // The module instantiated, set by the semantic pass
+	}
+
+	// What a port or parameter of an instance is connected to
+	PortConn struct {
+		Port  Ident // Linked to the port of the module by the semantic pass
+		Value Expr
+	}
+
 	ModuleDecl struct {
 		Name   Ident
+		Consts []ValueDecl // Compile time parameters, which set a value for each specialization
 		Params []ParamDecl
 		Block  BlockStmt
 	}
 )
 
+//go:generate stringer -type=Restart
+type Restart int // What a sequence does once it is done
+
+const (
+	Rearm Restart = iota // Waits to be started again, so it only runs once without a start condition
+	Once                 // Never runs again
+	Loop                 // Starts over on the next clock
+)
+
 //go:generate stringer -type=ParamDir
 type ParamDir int
 
@@ -321,14 +683,16 @@ const (
 	Inout
 )
 
-func (d ValueDecl) GetPos() [2]int  { return d.Name.GetPos() }
-func (d SignalDecl) GetPos() [2]int { return d.Name.GetPos() }
-func (d ModuleDecl) GetPos() [2]int { return d.Name.GetPos() }
+func (d ValueDecl) GetPos() [2]int    { return d.Name.GetPos() }
+func (d SignalDecl) GetPos() [2]int   { return d.Name.GetPos() }
+func (d ModuleDecl) GetPos() [2]int   { return d.Name.GetPos() }
+func (d InstanceDecl) GetPos() [2]int { return d.Name.GetPos() }
 
-func (*ValueDecl) declNode()  {}
-func (*SignalDecl) declNode() {}
-func (*ParamDecl) declNode()  {}
-func (*ModuleDecl) declNode() {}
+func (*ValueDecl) declNode()    {}
+func (*SignalDecl) declNode()   {}
+func (*ParamDecl) declNode()    {}
+func (*ModuleDecl) declNode()   {}
+func (*InstanceDecl) declNode() {}
 
 func (d ClockDecl) String() string {
 	var str string
@@ -347,6 +711,9 @@ func (d ClockDecl) String() string {
 
 func (d SignalDecl) String() string {
 	var str string
+	if d.Latch {
+		str += "latch "
+	}
 	if d.Width > 1 {
 		str += "[" + fmt.Sprint(d.Width) + "] "
 	}
@@ -356,6 +723,10 @@ func (d SignalDecl) String() string {
 		str += d.Clock.String()
 	}
 
+	if d.Init != nil {
+		str += " = " + d.Init.String()
+	}
+
 	return str
 }
 
@@ -366,17 +737,37 @@ func (d ParamDecl) String() string {
 	return str
 }
 
-func (d ModuleDecl) String() string {
+func (d ValueDecl) String() string {
 	var str string
+	str += "const "
+	if d.Width > 0 {
+		str += "[" + fmt.Sprint(d.Width) + "] "
+	}
 	str += d.Name.Name
-	str += "("
-	for i, param := range d.Params {
-		str += param.String()
-		if i < len(d.Params)-1 {
-			str += ", "
-		}
+	str += " = " + d.Value.String()
+	return str
+}
+
+func (d InstanceDecl) String() string {
+	var conns []string
+	for _, conn := range d.Conns {
+		conns = append(conns, conn.Port.Name+": "+conn.Value.String())
 	}
-	str += ")\n"
+	return d.Module.Name + " " + d.Name.Name + "(" + strings.Join(conns, ", ") + ")"
+}
+
+func (d ModuleDecl) String() string {
+	var params []string
+	for _, value := range d.Consts {
+		params = append(params, value.String())
+	}
+	for _, param := range d.Params {
+		params = append(params, param.String())
+	}
+
+	var str string
+	str += d.Name.Name
+	str += "(" + strings.Join(params, ", ") + ")\n"
 
 	str += d.Block.String(1)
 
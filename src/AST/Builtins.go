@@ -0,0 +1,144 @@
+package AST
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Functions built into the language, all evaluated at compile time:
+//
+//	clog2(N)          bits needed to count N values, the ceiling of log2 of N
+//	bits(N)           bits needed to store the value N
+//	width(Signal)     the declared width of a signal
+//	max(A, ...)       the largest of its arguments
+//	min(A, ...)       the smallest of its arguments
+//	abs(N)            N without its sign
+
+// A built in function, along with the number of arguments it takes
+type Builtin struct {
+	MinArgs, MaxArgs int // MaxArgs is -1 if there is no limit
+	Computable       func(args []Expr) bool
+	Eval             func(args []*big.Int) (*big.Int, error)
+}
+
+var Builtins = map[string]Builtin{
+	"clog2": {1, 1, constantArgs, evalClog2},
+	"bits":  {1, 1, constantArgs, evalBits},
+	"width": {1, 1, signalArg, nil}, // Evaluated from the signal rather than its value
+	"max":   {1, -1, constantArgs, evalMax},
+	"min":   {1, -1, constantArgs, evalMin},
+	"abs":   {1, 1, constantArgs, evalAbs},
+}
+
+// The names of every built in function, sorted
+func BuiltinNames() []string {
+	var names []string
+	for name := range Builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func constantArgs(args []Expr) bool {
+	for _, arg := range args {
+		if !arg.IsComputable() {
+			return false
+		}
+	}
+	return true
+}
+
+// The signal a call to width refers to, if its width is known
+func widthSignal(args []Expr) *SignalDecl {
+	id, ok := args[0].(*Ident)
+	if !ok || id.Obj == nil || id.Obj.Signal() == nil {
+		return nil
+	}
+	decl := id.Obj.Signal()
+	//Signals without a width are inferred later, except for ports which are a single bit
+	if decl.Width == 0 && (decl.WidthExpr != nil || id.Obj.Kind != Port) {
+		return nil
+	}
+	return decl
+}
+
+func signalArg(args []Expr) bool {
+	return widthSignal(args) != nil
+}
+
+func callComputable(x *CallExpr) bool {
+	fn, ok := Builtins[x.Fn]
+	return ok && fn.Accepts(len(x.Args)) && fn.Computable(x.Args)
+}
+
+func evalCall(x *CallExpr) (*big.Int, error) {
+	if x.Fn == "width" {
+		decl := widthSignal(x.Args)
+		if decl.Width == 0 {
+			return big.NewInt(1), nil
+		}
+		return big.NewInt(int64(decl.Width)), nil
+	}
+
+	var args []*big.Int
+	for _, arg := range x.Args {
+		val, err := Eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, val)
+	}
+	return Builtins[x.Fn].Eval(args)
+}
+
+func evalClog2(args []*big.Int) (*big.Int, error) {
+	if args[0].Sign() < 0 {
+		return nil, fmt.Errorf("clog2 of negative value %s", args[0])
+	}
+	if args[0].Cmp(big.NewInt(1)) <= 0 {
+		return big.NewInt(0), nil
+	}
+	//The bits needed for N-1, the highest value of a counter of N values
+	return big.NewInt(int64(new(big.Int).Sub(args[0], big.NewInt(1)).BitLen())), nil
+}
+
+func evalBits(args []*big.Int) (*big.Int, error) {
+	if args[0].Sign() < 0 {
+		return nil, fmt.Errorf("bits of negative value %s", args[0])
+	}
+	if args[0].Sign() == 0 {
+		return big.NewInt(1), nil
+	}
+	return big.NewInt(int64(args[0].BitLen())), nil
+}
+
+func evalMax(args []*big.Int) (*big.Int, error) {
+	res := args[0]
+	for _, arg := range args[1:] {
+		if arg.Cmp(res) > 0 {
+			res = arg
+		}
+	}
+	return res, nil
+}
+
+func evalMin(args []*big.Int) (*big.Int, error) {
+	res := args[0]
+	for _, arg := range args[1:] {
+		if arg.Cmp(res) < 0 {
+			res = arg
+		}
+	}
+	return res, nil
+}
+
+func evalAbs(args []*big.Int) (*big.Int, error) {
+	return new(big.Int).Abs(args[0]), nil
+}
+
+// Whether the function can be called with count arguments
+func (fn Builtin) Accepts(count int) bool {
+	return count >= fn.MinArgs && (fn.MaxArgs < 0 || count <= fn.MaxArgs)
+}
@@ -0,0 +1,194 @@
+package AST
+
+import "math/big"
+
+// Copies a module, so it can be specialized without changing the original.
+// Everything declared within the module is given a new object, names declared outside of it
+// such as constants of the file still refer to the same objects
+func CloneModule(mod ModuleDecl) ModuleDecl {
+	c := cloner{objects: map[*Object]*Object{}}
+
+	clone := ModuleDecl{Name: mod.Name}
+	clone.Consts = make([]ValueDecl, len(mod.Consts))
+	clone.Params = make([]ParamDecl, len(mod.Params))
+
+	//Ports can refer to each other, so they are all declared before any of them are copied
+	for i := range mod.Consts {
+		c.declare(mod.Consts[i].Name, &clone.Consts[i])
+	}
+	for i := range mod.Params {
+		c.declare(mod.Params[i].Name, &clone.Params[i])
+	}
+	for i, value := range mod.Consts {
+		clone.Consts[i] = c.value(value)
+	}
+	for i, param := range mod.Params {
+		clone.Params[i] = ParamDecl{SignalDecl: c.signal(param.SignalDecl), Dir: param.Dir}
+	}
+
+	clone.Block = *c.block(&mod.Block)
+	return clone
+}
+
+// Maps the objects of the module being copied to those of the copy
+type cloner struct {
+	objects map[*Object]*Object
+}
+
+func (c *cloner) declare(name Ident, decl Decl) {
+	if name.Obj != nil {
+		c.objects[name.Obj] = &Object{Kind: name.Obj.Kind, Name: name.Obj.Name, Decl: decl}
+	}
+}
+
+func (c *cloner) ident(id Ident) Ident {
+	if obj, ok := c.objects[id.Obj]; ok {
+		id.Obj = obj
+	}
+	return id
+}
+
+func (c *cloner) value(decl ValueDecl) ValueDecl {
+	return ValueDecl{Name: c.ident(decl.Name), WidthExpr: c.expr(decl.WidthExpr), Width: decl.Width, Value: c.expr(decl.Value)}
+}
+
+func (c *cloner) signal(decl SignalDecl) SignalDecl {
+	clone := SignalDecl{
+		Name:      c.ident(decl.Name),
+		WidthExpr: c.expr(decl.WidthExpr),
+		Width:     decl.Width,
+		Init:      c.expr(decl.Init),
+		Latch:     decl.Latch,
+	}
+	if decl.Clock != nil {
+		clone.Clock = &ClockDecl{Name: c.ident(decl.Clock.Name), Neg: decl.Clock.Neg}
+	}
+	return clone
+}
+
+func (c *cloner) block(blk *BlockStmt) *BlockStmt {
+	clone := &BlockStmt{StartPos: blk.StartPos, EndPos: blk.EndPos}
+	for _, stmt := range blk.StmtList {
+		clone.StmtList = append(clone.StmtList, c.stmt(stmt))
+	}
+	return clone
+}
+
+func (c *cloner) stmt(stmt Stmt) Stmt {
+	switch obj := stmt.(type) {
+	case nil:
+		return nil
+	case *BadStmt:
+		return &BadStmt{Pos: obj.Pos}
+	case *DeclStmt:
+		return &DeclStmt{Pos: obj.Pos, Decl: c.decl(obj.Decl)}
+	case *AssignStmt:
+		return &AssignStmt{Pos: obj.Pos, Op: obj.Op, LHS: c.expr(obj.LHS), RHS: c.expr(obj.RHS)}
+	case *IfStmt:
+		return &IfStmt{Pos: obj.Pos, Cond: c.expr(obj.Cond), Body: c.stmt(obj.Body), Else: c.stmt(obj.Else)}
+	case *LoopStmt:
+		return &LoopStmt{Pos: obj.Pos, Cond: c.expr(obj.Cond), Count: c.expr(obj.Count), Body: c.stmt(obj.Body)}
+	case *WaitStmt:
+		return &WaitStmt{Pos: obj.Pos, X: c.expr(obj.X), Cycles: obj.Cycles}
+	case *SwitchStmt:
+		clone := &SwitchStmt{Pos: obj.Pos, X: c.expr(obj.X)}
+		for _, arm := range obj.Cases {
+			cloneArm := CaseClause{Pos: arm.Pos, Body: c.stmt(arm.Body)}
+			for _, value := range arm.Values {
+				cloneArm.Values = append(cloneArm.Values, c.expr(value))
+			}
+			clone.Cases = append(clone.Cases, cloneArm)
+		}
+		return clone
+	case *BlockStmt:
+		return c.block(obj)
+	case *SequenceStmt:
+		clk := ClockDecl{Name: c.ident(obj.Clk.Name), Neg: obj.Clk.Neg}
+		clone := &SequenceStmt{StartPos: obj.StartPos, EndPos: obj.EndPos, Clk: clk, Start: c.expr(obj.Start), Restart: obj.Restart}
+		if obj.Busy != nil {
+			busy := c.ident(*obj.Busy)
+			clone.Busy = &busy
+		}
+		if obj.Done != nil {
+			done := c.ident(*obj.Done)
+			clone.Done = &done
+		}
+		clone.Inner = c.stmt(obj.Inner)
+		clone.Abort = c.expr(obj.Abort)
+		if obj.Cleanup != nil {
+			clone.Cleanup = c.stmt(obj.Cleanup)
+		}
+		return clone
+	}
+	panic("CloneModule: unexpected statement")
+}
+
+// Declarations within a block are given their object before their parts are copied,
+// which is fine as nothing can refer to its own declaration
+func (c *cloner) decl(decl Decl) Decl {
+	switch obj := decl.(type) {
+	case *SignalDecl:
+		clone := &SignalDecl{}
+		c.declare(obj.Name, clone)
+		*clone = c.signal(*obj)
+		return clone
+	case *ValueDecl:
+		clone := &ValueDecl{}
+		c.declare(obj.Name, clone)
+		*clone = c.value(*obj)
+		return clone
+	case *InstanceDecl:
+		clone := &InstanceDecl{Module: obj.Module, Def: obj.Def}
+		c.declare(obj.Name, clone)
+		clone.Name = c.ident(obj.Name)
+		for _, conn := range obj.Conns {
+			clone.Conns = append(clone.Conns, PortConn{Port: conn.Port, Value: c.expr(conn.Value)})
+		}
+		return clone
+	}
+	panic("CloneModule: unexpected declaration")
+}
+
+func (c *cloner) expr(expr Expr) Expr {
+	switch obj := expr.(type) {
+	case nil:
+		return nil
+	case *BadExpr:
+		return &BadExpr{Pos: obj.Pos}
+	case *Ident:
+		clone := c.ident(*obj)
+		return &clone
+	case *Literal:
+		clone := *obj
+		clone.Value = new(big.Int).Set(obj.Value)
+		if obj.Mask != nil {
+			clone.Mask = new(big.Int).Set(obj.Mask)
+		}
+		return &clone
+	case *ParenExpr:
+		return &ParenExpr{StartPos: obj.StartPos, EndPos: obj.EndPos, X: c.expr(obj.X)}
+	case *CallExpr:
+		clone := &CallExpr{Pos: obj.Pos, Fn: obj.Fn}
+		for _, arg := range obj.Args {
+			clone.Args = append(clone.Args, c.expr(arg))
+		}
+		return clone
+	case *UnaryExpr:
+		return &UnaryExpr{Pos: obj.Pos, Op: obj.Op, X: c.expr(obj.X)}
+	case *MathExpr:
+		return &MathExpr{Pos: obj.Pos, Op: obj.Op, LHS: c.expr(obj.LHS), RHS: c.expr(obj.RHS)}
+	case *IndexExpr:
+		return &IndexExpr{Pos: obj.Pos, X: c.expr(obj.X), Index: c.expr(obj.Index)}
+	case *SliceExpr:
+		return &SliceExpr{Pos: obj.Pos, X: c.expr(obj.X), Kind: obj.Kind, Left: c.expr(obj.Left), Right: c.expr(obj.Right)}
+	case *ConcatExpr:
+		clone := &ConcatExpr{StartPos: obj.StartPos, EndPos: obj.EndPos}
+		for _, part := range obj.Parts {
+			clone.Parts = append(clone.Parts, c.expr(part))
+		}
+		return clone
+	case *ReplicateExpr:
+		return &ReplicateExpr{StartPos: obj.StartPos, EndPos: obj.EndPos, Count: c.expr(obj.Count), X: c.expr(obj.X)}
+	}
+	panic("CloneModule: unexpected expression")
+}
@@ -0,0 +1,154 @@
+package AST
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Constant expressions are evaluated as integers of unlimited size.
+// Comparisons and logical operators give 1 for true and 0 for false.
+// A bitwise not depends on the width of its operand, so it is only evaluated when that is known
+
+// Largest shift allowed in a constant expression, to keep values to a sensible size
+const maxConstShift = 1 << 16
+
+// Evaluates an expression at compile time
+func Eval(expr Expr) (*big.Int, error) {
+	if !expr.IsComputable() {
+		return nil, fmt.Errorf("not a constant")
+	}
+
+	switch x := expr.(type) {
+	case *Literal:
+		return new(big.Int).Set(x.Value), nil
+	case *Ident:
+		return Eval(x.Obj.Value())
+	case *ParenExpr:
+		return Eval(x.X)
+	case *UnaryExpr:
+		return evalUnary(x)
+	case *MathExpr:
+		return evalMath(x)
+	case *CallExpr:
+		return evalCall(x)
+	}
+	return nil, fmt.Errorf("not a constant")
+}
+
+func evalUnary(x *UnaryExpr) (*big.Int, error) {
+	val, err := Eval(x.X)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.Op {
+	case Negate:
+		return val.Neg(val), nil
+	case Not:
+		return boolValue(val.Sign() == 0), nil
+	case BitNot:
+		mask := new(big.Int).Lsh(big.NewInt(1), uint(SizedWidth(x.X)))
+		mask.Sub(mask, big.NewInt(1))
+		return val.Not(val).And(val, mask), nil
+	case ReduceOr:
+		return boolValue(val.Sign() != 0), nil
+	case ReduceXor:
+		count := 0
+		for _, word := range new(big.Int).Abs(val).Bits() {
+			for ; word != 0; word &= word - 1 {
+				count++
+			}
+		}
+		return big.NewInt(int64(count % 2)), nil
+	}
+	return nil, fmt.Errorf("%v can't be evaluated", x.Op)
+}
+
+// The width of a value that is known before the semantic pass sizes expressions, 0 if it isn't
+func SizedWidth(expr Expr) int {
+	switch x := expr.(type) {
+	case *Literal:
+		return x.Width
+	case *ParenExpr:
+		return SizedWidth(x.X)
+	case *Ident:
+		if x.Obj != nil {
+			if decl, ok := x.Obj.Decl.(*ValueDecl); ok {
+				return decl.Width
+			}
+		}
+	case *UnaryExpr:
+		if x.Op == BitNot {
+			return SizedWidth(x.X)
+		}
+	}
+	return 0
+}
+
+func evalMath(x *MathExpr) (*big.Int, error) {
+	lhs, err := Eval(x.LHS)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := Eval(x.RHS)
+	if err != nil {
+		return nil, err
+	}
+
+	res := new(big.Int)
+	switch x.Op {
+	case Add:
+		return res.Add(lhs, rhs), nil
+	case Sub:
+		return res.Sub(lhs, rhs), nil
+	case Multi:
+		return res.Mul(lhs, rhs), nil
+	case Div, Mod:
+		if rhs.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		//Verilog division truncates towards zero
+		if x.Op == Div {
+			return res.Quo(lhs, rhs), nil
+		}
+		return res.Rem(lhs, rhs), nil
+	case LShift, ArithLShift, RShift, ArithRShift:
+		if rhs.Sign() < 0 || rhs.Cmp(big.NewInt(maxConstShift)) > 0 {
+			return nil, fmt.Errorf("shift by %s is out of range", rhs)
+		}
+		if x.Op == LShift || x.Op == ArithLShift {
+			return res.Lsh(lhs, uint(rhs.Uint64())), nil
+		}
+		return res.Rsh(lhs, uint(rhs.Uint64())), nil
+	case Equals:
+		return boolValue(lhs.Cmp(rhs) == 0), nil
+	case NotEquals:
+		return boolValue(lhs.Cmp(rhs) != 0), nil
+	case Less:
+		return boolValue(lhs.Cmp(rhs) < 0), nil
+	case LessEq:
+		return boolValue(lhs.Cmp(rhs) <= 0), nil
+	case Greater:
+		return boolValue(lhs.Cmp(rhs) > 0), nil
+	case GreaterEq:
+		return boolValue(lhs.Cmp(rhs) >= 0), nil
+	case BitAnd:
+		return res.And(lhs, rhs), nil
+	case BitOr:
+		return res.Or(lhs, rhs), nil
+	case BitXor:
+		return res.Xor(lhs, rhs), nil
+	case LogicAnd:
+		return boolValue(lhs.Sign() != 0 && rhs.Sign() != 0), nil
+	case LogicOr:
+		return boolValue(lhs.Sign() != 0 || rhs.Sign() != 0), nil
+	}
+	return nil, fmt.Errorf("%v can't be evaluated", x.Op)
+}
+
+func boolValue(b bool) *big.Int {
+	if b {
+		return big.NewInt(1)
+	}
+	return big.NewInt(0)
+}
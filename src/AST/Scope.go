@@ -0,0 +1,96 @@
+package AST
+
+import "sort"
+
+// Like go/ast, identifiers are linked to the object they refer to.
+// The links are filled in by the semantic pass, so later stages never look names up again
+
+//go:generate stringer -type=ObjKind
+type ObjKind int
+
+const (
+	Port     ObjKind = iota // A parameter of a module
+	Signal                  // A sig declared within a module
+	Const                   // A named value known at compile time
+	Instance                // A module used within another
+)
+
+// A named entity that identifiers can refer to
+type Object struct {
+	Kind ObjKind
+	Name string
+	Decl Decl // *ParamDecl, *SignalDecl, *ValueDecl or *InstanceDecl
+}
+
+// The signal behind a port or sig declaration, nil for constants
+func (obj *Object) Signal() *SignalDecl {
+	switch decl := obj.Decl.(type) {
+	case *ParamDecl:
+		return &decl.SignalDecl
+	case *SignalDecl:
+		return decl
+	}
+	return nil
+}
+
+// The value of a constant, nil for signals or a constant whose value isn't constant
+func (obj *Object) Value() Expr {
+	decl, ok := obj.Decl.(*ValueDecl)
+	if !ok || !decl.Value.IsComputable() {
+		return nil
+	}
+	return decl.Value
+}
+
+func (obj *Object) GetPos() [2]int { return obj.Decl.GetPos() }
+
+// The names declared within a module or block
+type Scope struct {
+	Outer   *Scope
+	Objects map[string]*Object
+}
+
+func NewScope(outer *Scope) *Scope {
+	return &Scope{Outer: outer, Objects: map[string]*Object{}}
+}
+
+// Finds a name declared directly in this scope
+func (s *Scope) Lookup(name string) *Object {
+	return s.Objects[name]
+}
+
+// Finds a name, searching from this scope outwards
+func (s *Scope) Resolve(name string) *Object {
+	for ; s != nil; s = s.Outer {
+		if obj := s.Objects[name]; obj != nil {
+			return obj
+		}
+	}
+	return nil
+}
+
+// Adds the object to the scope, unless the name is already taken.
+// Returns the object that was already there, or nil if it was added
+func (s *Scope) Insert(obj *Object) *Object {
+	if prev := s.Objects[obj.Name]; prev != nil {
+		return prev
+	}
+	s.Objects[obj.Name] = obj
+	return nil
+}
+
+// Every name visible from this scope, in sorted order
+func (s *Scope) Names() []string {
+	seen := map[string]bool{}
+	var names []string
+	for ; s != nil; s = s.Outer {
+		for name := range s.Objects {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -12,17 +12,35 @@ func _() {
 	_ = x[AsmtReg-1]
 	_ = x[LShift-2]
 	_ = x[RShift-3]
-	_ = x[Add-4]
-	_ = x[Sub-5]
-	_ = x[Multi-6]
-	_ = x[Div-7]
-	_ = x[Bracket-8]
-	_ = x[Equals-9]
+	_ = x[ArithLShift-4]
+	_ = x[ArithRShift-5]
+	_ = x[Add-6]
+	_ = x[Sub-7]
+	_ = x[Multi-8]
+	_ = x[Div-9]
+	_ = x[Mod-10]
+	_ = x[Equals-11]
+	_ = x[NotEquals-12]
+	_ = x[Less-13]
+	_ = x[LessEq-14]
+	_ = x[Greater-15]
+	_ = x[GreaterEq-16]
+	_ = x[BitAnd-17]
+	_ = x[BitOr-18]
+	_ = x[BitXor-19]
+	_ = x[LogicAnd-20]
+	_ = x[LogicOr-21]
+	_ = x[Negate-22]
+	_ = x[Not-23]
+	_ = x[BitNot-24]
+	_ = x[ReduceAnd-25]
+	_ = x[ReduceOr-26]
+	_ = x[ReduceXor-27]
 }
 
-const _Operation_name = "AsmtAsmtRegLShiftRShiftAddSubMultiDivBracketEquals"
+const _Operation_name = "AsmtAsmtRegLShiftRShiftArithLShiftArithRShiftAddSubMultiDivModEqualsNotEqualsLessLessEqGreaterGreaterEqBitAndBitOrBitXorLogicAndLogicOrNegateNotBitNotReduceAndReduceOrReduceXor"
 
-var _Operation_index = [...]uint8{0, 4, 11, 17, 23, 26, 29, 34, 37, 44, 50}
+var _Operation_index = [...]uint8{0, 4, 11, 17, 23, 34, 45, 48, 51, 56, 59, 62, 68, 77, 81, 87, 94, 103, 109, 114, 120, 128, 135, 141, 144, 150, 159, 167, 176}
 
 func (i Operation) String() string {
 	if i < 0 || i >= Operation(len(_Operation_index)-1) {
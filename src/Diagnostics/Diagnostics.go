@@ -0,0 +1,115 @@
+package Diagnostics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:generate stringer -type=Severity
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+// A single problem found while compiling
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Message  string
+	File     string
+	Start    [2]int // line and column of the first character
+	End      [2]int // line and column one past the last character
+	Notes    []string
+}
+
+func Errorf(code Code, start [2]int, end [2]int, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{Severity: Error, Code: code, Message: fmt.Sprintf(format, args...), Start: start, End: end}
+}
+
+func Warningf(code Code, start [2]int, end [2]int, format string, args ...interface{}) Diagnostic {
+	return Diagnostic{Severity: Warning, Code: code, Message: fmt.Sprintf(format, args...), Start: start, End: end}
+}
+
+// Attaches a note to the diagnostic, returning it for chaining
+func (d Diagnostic) WithNote(format string, args ...interface{}) Diagnostic {
+	d.Notes = append(d.Notes, fmt.Sprintf(format, args...))
+	return d
+}
+
+// stringer interface
+// file:line:col: error[code]: message
+func (d Diagnostic) String() string {
+	var str string
+
+	if d.File != "" {
+		str += d.File + ":"
+	}
+	str += fmt.Sprintf("%d:%d: ", d.Start[0], d.Start[1])
+
+	str += strings.ToLower(d.Severity.String())
+	if d.Code != "" {
+		str += "[" + string(d.Code) + "]"
+	}
+	str += ": " + d.Message
+
+	for _, note := range d.Notes {
+		str += "\n    note: " + note
+	}
+
+	return str
+}
+
+// Collects diagnostics, safe to append to from multiple goroutines
+type List struct {
+	lock  sync.Mutex
+	items []Diagnostic
+}
+
+func (l *List) Add(d Diagnostic) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.items = append(l.items, d)
+}
+
+// A copy of the collected diagnostics
+func (l *List) Items() []Diagnostic {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]Diagnostic{}, l.items...)
+}
+
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Fills in the file of any diagnostics that were raised without one
+func SetFile(diags []Diagnostic, file string) {
+	for i := range diags {
+		if diags[i].File == "" {
+			diags[i].File = file
+		}
+	}
+}
+
+// Orders diagnostics by their position in the file
+func Sort(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].File != diags[j].File {
+			return diags[i].File < diags[j].File
+		}
+		if diags[i].Start[0] != diags[j].Start[0] {
+			return diags[i].Start[0] < diags[j].Start[0]
+		}
+		return diags[i].Start[1] < diags[j].Start[1]
+	})
+}
@@ -0,0 +1,68 @@
+package Diagnostics
+
+// Identifies the kind of problem so tools can filter or look up documentation.
+// The first letter gives the stage that raised it
+type Code string
+
+const (
+	// Lexer
+	ReadFailure         Code = "L001"
+	UnterminatedComment Code = "L002"
+
+	// Parser
+	UnexpectedToken Code = "P001"
+	InvalidLiteral  Code = "P002"
+	LiteralOverflow Code = "P003"
+
+	// Semantic checks
+	UndeclaredName    Code = "S001"
+	DuplicateName     Code = "S002"
+	ShadowedName      Code = "S003"
+	WidthTruncation   Code = "S004"
+	ValueOverflow     Code = "S005"
+	WidthMismatch     Code = "S006"
+	UninferredWidth   Code = "S007"
+	UnclockedRegister Code = "S008"
+	ClockedWire       Code = "S009"
+	InputAssignment   Code = "S010"
+	UndrivenOutput    Code = "S011"
+	CrossDomainDriver Code = "S012"
+	LatchInferred     Code = "S013"
+	UnneededLatch     Code = "S014"
+	MultipleDrivers   Code = "S015"
+	CombinationalLoop Code = "S016"
+	UnsyncCrossing    Code = "S017"
+	MultiBitSync      Code = "S018"
+	LogicBeforeSync   Code = "S019"
+	NotConstant       Code = "S020"
+	InvalidWidth      Code = "S021"
+	InvalidConstant   Code = "S022"
+	UnknownFunction   Code = "S023"
+	ArgumentCount     Code = "S024"
+	ConstantMisuse    Code = "S025"
+	UnknownModule     Code = "S026"
+	UnknownPort       Code = "S027"
+	UnconnectedPort   Code = "S028"
+	RecursiveInstance Code = "S029"
+	PortWidthMismatch Code = "S030"
+	PortDirection     Code = "S031"
+	PortClockMismatch Code = "S032"
+	NestedInstance    Code = "S033"
+	IncompleteSwitch  Code = "S034"
+	UnreachableArm    Code = "S035"
+	SequenceOnly      Code = "S036"
+	EmptyLoop         Code = "S037"
+	NestedStart       Code = "S038"
+	AbortedRegister   Code = "S039"
+	InstanceMisuse    Code = "S040"
+
+	// Verilog backend
+	UnsupportedConstruct Code = "V001"
+	UndeclaredSignal     Code = "V002"
+	MissingClock         Code = "V003"
+	ClockMismatch        Code = "V004"
+	SequenceAssignment   Code = "V005"
+	WriteFailure         Code = "V006"
+	SelectOutOfRange     Code = "V007"
+	NonConstantWidth     Code = "V008"
+)
@@ -3,7 +3,11 @@ package Lexer
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
 )
 
 // token types split into enum per type for easier parsing (on switch)
@@ -15,11 +19,14 @@ const (
 	Iden                // any named identifier
 	Literal             // can be split into the different literal types during lexing
 	Direction           // signal direction, input or output
-	Spec                // Wire / Reg / Param, specifier for 'variables'
+	Spec                // sig / latch / const, specifier for 'variables'
 	Default             // Default case
 	If
 	Else
 	Switch
+	Wait
+	While
+	Repeat
 	LParen
 	RParen
 	LCurly
@@ -33,12 +40,24 @@ const (
 	Asmt //Assignment
 	Cmp
 	Unknown
+	EOF // end of the file
 )
 
+// value of the token sent once the whole file has been read
+const EOFValue = "<EOF>"
+
 type Token struct {
 	// Type  TokenType
-	Value string // store the direct value
-	Pos   [2]int // store the position for error reporting
+	Value     string    // store the direct value
+	Pos       [2]int    // store the position for error reporting
+	Comments  []Comment // comments preceding the token, only kept when requested
+	LineStart bool      // first token on its line, used to resynchronize after errors
+}
+
+// A line or block comment, kept as trivia for tools that want to preserve them
+type Comment struct {
+	Text string // full text, including the comment markers
+	Pos  [2]int
 }
 
 // stringer interface
@@ -70,7 +89,7 @@ func (t Token) IsLiteral() bool {
 
 func (t Token) IsMath() bool {
 	switch t.Value {
-	case "+", "-", "*", "/":
+	case "+", "-", "*", "/", "%":
 		return true
 	}
 	return false
@@ -86,7 +105,7 @@ func (t Token) IsLogical() bool {
 
 func (t Token) IsBitwise() bool {
 	switch t.Value {
-	case "~", "&", "|", ">>", "<<":
+	case "~", "&", "|", "^", ">>", "<<", ">>>", "<<<":
 		return true
 	}
 	return false
@@ -94,7 +113,7 @@ func (t Token) IsBitwise() bool {
 
 func (t Token) IsComparison() bool {
 	switch t.Value {
-	case "==", "<", ">", "<=":
+	case "==", "!=", "<", ">", "<=", ">=":
 		return true
 	}
 	return false
@@ -144,7 +163,7 @@ func (t Token) IsAssignment() bool {
 
 func (t Token) IsKeyword() bool {
 	switch t.Value {
-	case "sig", "in", "out", "inout", "if", "else":
+	case "sig", "in", "out", "inout", "latch", "const", "if", "else", "switch", "default", "wait", "while", "repeat", "when", "once", "loop", "busy", "done", "abort":
 		return true
 	}
 	return false
@@ -158,6 +177,10 @@ func (t Token) IsSpec() bool {
 	return false
 }
 
+func (t Token) IsEOF() bool {
+	return t.Value == EOFValue
+}
+
 func (t Token) IsEOL() bool {
 	switch t.Value {
 	case ";", "\n":
@@ -173,7 +196,18 @@ func (t Token) GetType() TokenType {
 	if t.IsLiteral() && !t.IsKeyword() {
 		return Literal
 	}
-	return tokenMap[t.Value]
+	if t.IsEOF() {
+		return EOF
+	}
+	if tokenType, ok := tokenMap[t.Value]; ok {
+		return tokenType
+	}
+	return Unknown
+}
+
+// position one past the last character of the token
+func (t Token) End() [2]int {
+	return [2]int{t.Pos[0], t.Pos[1] + len(t.Value)}
 }
 
 // keyword list
@@ -181,13 +215,16 @@ var tokenMap = map[string]TokenType{
 	"in":      Direction,
 	"out":     Direction,
 	"inout":   Direction,
-	"wire":    Spec,
-	"reg":     Spec,
-	"var":     Spec,
+	"sig":     Spec,
+	"latch":   Spec,
+	"const":   Spec,
 	"if":      If,
 	"else":    Else,
 	"switch":  Switch,
 	"default": Default,
+	"wait":    Wait,
+	"while":   While,
+	"repeat":  Repeat,
 	",":       Comma,
 	"{":       LCurly,
 	"}":       RCurly,
@@ -198,16 +235,30 @@ var tokenMap = map[string]TokenType{
 	";":       EOL,
 	"@":       Atmark,
 	":":       Colon,
+	"+:":      Colon,
+	"-:":      Colon,
 	"*":       Math,
 	"/":       Math,
+	"%":       Math,
 	"-":       Math,
 	"+":       Math,
 	"<<":      Math,
 	">>":      Math,
+	"<<<":     Math,
+	">>>":     Math,
 	"!":       Math,
+	"~":       Math,
+	"&":       Math,
+	"|":       Math,
+	"^":       Math,
+	"&&":      Math,
+	"||":      Math,
 	"=":       Asmt,
 	"<-":      Asmt,
 	"==":      Cmp,
+	"!=":      Cmp,
+	"<":       Cmp,
+	">":       Cmp,
 	">=":      Cmp,
 	"<=":      Cmp,
 }
@@ -217,6 +268,9 @@ var tokenMap = map[string]TokenType{
 type Lexer struct {
 	file   *os.File
 	tokens PeekableQueue
+	diags  *D.List
+
+	KeepComments bool // attach comments to the following token instead of discarding them
 }
 
 func NewLexer(fileName string) (Lexer, error) {
@@ -225,6 +279,7 @@ func NewLexer(fileName string) (Lexer, error) {
 		err error
 	)
 	lex.tokens = NewQueue()
+	lex.diags = &D.List{}
 	lex.file, err = os.Open(fileName)
 
 	return lex, err // err will be nil if nothing was thrown, no need to check here
@@ -257,7 +312,32 @@ func (lex *Lexer) NextExists() bool {
 	return !lex.tokens.IsEmpty()
 }
 
-func multiToken(first rune, next rune) bool {
+func (lex *Lexer) FileName() string {
+	return lex.file.Name()
+}
+
+// problems found while tokenizing, only complete once the EOF token has been read
+func (lex *Lexer) Diagnostics() []D.Diagnostic {
+	diags := lex.diags.Items()
+	D.SetFile(diags, lex.FileName())
+	return diags
+}
+
+func (lex Lexer) readError(err error, pos [2]int) {
+	lex.diags.Add(D.Errorf(D.ReadFailure, pos, pos, "Could not read file: %v", err))
+}
+
+// operators made up of more than one character
+var multiCharOperators = []string{
+	"==", "!=", "<=", ">=",
+	"<<", ">>", "<<<", ">>>",
+	"&&", "||",
+	"<-",
+	"+:", "-:",
+}
+
+// check if the next rune continues the token built so far
+func multiToken(val string, next rune) bool {
 	// check if rune is part of a Name or Value
 	checkNameVal := func(val rune) bool {
 		return (val >= '0' && val <= '9') || // test if number
@@ -266,74 +346,138 @@ func multiToken(first rune, next rune) bool {
 			val == '_' // test if underscore
 	}
 
-	//Check if rune is part of a comparison
-	checkCmp := func(val rune) bool {
-		return (val == '<' || val == '=' || val == '>')
-	}
-
 	//Check if this is a valid Name/Value multi token
-	if checkNameVal(first) && checkNameVal(next) {
+	if checkNameVal(rune(val[0])) && checkNameVal(next) {
 		return true
 	}
 
-	//Check if this is a valid Comparison multi token
-	if checkCmp(first) && checkCmp(next) {
+	//Check for the width of a sized literal, such as 8'hFF
+	if val[0] >= '0' && val[0] <= '9' && next == '\'' && !strings.ContainsRune(val, '\'') {
 		return true
 	}
 
-	//Check reg assigment
-	if first == '<' && next == '-' {
+	//Check for the don't care bits of a switch value, such as 4'b1??0
+	if val[0] >= '0' && val[0] <= '9' && next == '?' {
 		return true
 	}
 
+	//Check if this builds towards a multi character operator
+	for _, op := range multiCharOperators {
+		if strings.HasPrefix(op, val+string(next)) {
+			return true
+		}
+	}
+
 	return false
 }
 
+// Consumes a comment whose opening '/' has already been read.
+// pos is the position of the opening '/' and is advanced past the comment
+func (lex Lexer) readComment(reader *bufio.Reader, pos *[2]int) Comment {
+	comment := Comment{Text: "/", Pos: *pos}
+	pos[1]++
+
+	kind, _, _ := reader.ReadRune()
+	comment.Text += string(kind)
+	pos[1]++
+
+	// line comments run until the end of the line, leaving the new line to be counted by the tokenizer
+	if kind == '/' {
+		for {
+			next, err := reader.Peek(1)
+			if err != nil || next[0] == '\n' || next[0] == '\r' {
+				return comment
+			}
+			r, _, _ := reader.ReadRune()
+			comment.Text += string(r)
+			pos[1]++
+		}
+	}
+
+	// block comments can be nested
+	depth := 1
+	for depth > 0 {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			lex.diags.Add(D.Errorf(D.UnterminatedComment, comment.Pos, *pos, "Unterminated block comment"))
+			return comment
+		}
+		comment.Text += string(r)
+
+		if r == '\n' {
+			pos[0]++
+			pos[1] = 1
+			continue
+		}
+		pos[1]++
+
+		next, err := reader.Peek(1)
+		if err != nil {
+			continue
+		}
+		if (r == '/' && next[0] == '*') || (r == '*' && next[0] == '/') {
+			if r == '/' {
+				depth++
+			} else {
+				depth--
+			}
+			reader.ReadRune()
+			comment.Text += string(next[0])
+			pos[1]++
+		}
+	}
+
+	return comment
+}
+
 func (lex Lexer) Tokenizer() {
 	reader := bufio.NewReader(lex.file)
 
+	pos := [2]int{1, 1} // position / head tracker for error reporting
+
 	defer lex.file.Close()
 	defer lex.tokens.Close()
+	defer func() { lex.tokens.PushBack(Token{Value: EOFValue, Pos: pos, LineStart: true}) }()
 
-	pos := [2]int{1, 1} // position / head tracker for error reporting
+	var comments []Comment // comments waiting to be attached to the next token
+	lineStart := true
 
 	for {
 		var (
-			charAdd int    = 1
+			charAdd int    = 0
 			val     string = ""
 
-			nextRune  rune
-			firstRune rune
+			nextRune rune
 		)
 
 		//Do While type loop. Is guaranteed to execute at least once.
 		//Will continue to loop based on the MultiToken condition
-		for buildVal := true; buildVal; buildVal = multiToken(firstRune, nextRune) {
+		for buildVal := true; buildVal; buildVal = multiToken(val, nextRune) {
 			newRune, _, err := reader.ReadRune()
 			if err != nil {
-				if err.Error() != "EOF" {
-					fmt.Println("Error:", err) // temp
+				if err != io.EOF {
+					lex.readError(err, pos)
 				}
 				return
 			}
 			val += string(newRune)
 			charAdd++
 
+			nextRune = 0
 			nextVal, err := reader.Peek(1)
 			if err != nil {
-				if err.Error() != "EOF" {
-					fmt.Println("Error:", err) // temp
+				if err != io.EOF {
+					lex.readError(err, pos)
 				}
 				break
 			}
 
-			firstRune = rune(val[0])
 			nextRune = rune(nextVal[0])
 		}
 
 		// single & multi char tokenizing
 		switch val {
-		case " ":
+		case " ", "\t":
 			{
 				pos[1] += 1
 				continue
@@ -342,13 +486,24 @@ func (lex Lexer) Tokenizer() {
 			{
 				pos[0] += 1
 				pos[1] = 1
+				lineStart = true
 				continue
 			} // ignore new lines
 		case "\r":
 			continue // ignore carriage returns (don't need to count for position tracking, always bundled with new line)
+		case "/":
+			if nextRune == '/' || nextRune == '*' {
+				comment := lex.readComment(reader, &pos)
+				if lex.KeepComments {
+					comments = append(comments, comment)
+				}
+				continue
+			}
 		}
 
-		lex.tokens.PushBack(Token{val, pos})
+		lex.tokens.PushBack(Token{Value: val, Pos: pos, Comments: comments, LineStart: lineStart})
+		comments = nil
+		lineStart = false
 		pos[1] += charAdd
 	}
 }
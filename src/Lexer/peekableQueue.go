@@ -1,15 +1,5 @@
 package Lexer
 
-import (
-	"fmt"
-	"os"
-)
-
-func displayError(str string) {
-	fmt.Println("Error: ", str)
-	os.Exit(-1)
-}
-
 // if there is only one reader thread, the lock is not needed
 // move to list for peeking ahead multiple tokens?
 type PeekableQueue struct {
@@ -32,16 +22,20 @@ func (q PeekableQueue) PushBack(t Token) {
 	q.queue <- t
 }
 
+// reading past the end of the queue keeps returning the EOF token
+func (q *PeekableQueue) receive() Token {
+	t, ok := <-q.queue
+	if !ok {
+		return Token{Value: EOFValue, Pos: q.peeked.Pos}
+	}
+	return t
+}
+
 func (q *PeekableQueue) PeekNext() Token {
 	var retVal Token
 
 	if q.peeked.Value == "" {
-		var ok bool
-		q.peeked, ok = <-q.queue
-
-		if !ok {
-			displayError("Unexpected end of file")
-		}
+		q.peeked = q.receive()
 	}
 
 	retVal = q.peeked
@@ -53,12 +47,7 @@ func (q *PeekableQueue) GetNext() Token {
 	var retVal Token
 
 	if q.peeked.Value == "" {
-		var ok bool
-		retVal, ok = <-q.queue
-
-		if !ok {
-			displayError("Unexpected end of file")
-		}
+		retVal = q.receive()
 	} else {
 		retVal = q.peeked
 		q.peeked.Value = ""
@@ -68,11 +57,5 @@ func (q *PeekableQueue) GetNext() Token {
 }
 
 func (q *PeekableQueue) IsEmpty() bool {
-	ok := true
-
-	if q.peeked.Value == "" {
-		q.peeked, ok = <-q.queue
-	}
-
-	return !ok
+	return q.PeekNext().IsEOF()
 }
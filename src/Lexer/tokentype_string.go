@@ -17,24 +17,28 @@ func _() {
 	_ = x[If-6]
 	_ = x[Else-7]
 	_ = x[Switch-8]
-	_ = x[LParen-9]
-	_ = x[RParen-10]
-	_ = x[LCurly-11]
-	_ = x[RCurly-12]
-	_ = x[LBrace-13]
-	_ = x[RBrace-14]
-	_ = x[Atmark-15]
-	_ = x[Math-16]
-	_ = x[Comma-17]
-	_ = x[Colon-18]
-	_ = x[Asmt-19]
-	_ = x[Cmp-20]
-	_ = x[Unknown-21]
+	_ = x[Wait-9]
+	_ = x[While-10]
+	_ = x[Repeat-11]
+	_ = x[LParen-12]
+	_ = x[RParen-13]
+	_ = x[LCurly-14]
+	_ = x[RCurly-15]
+	_ = x[LBrace-16]
+	_ = x[RBrace-17]
+	_ = x[Atmark-18]
+	_ = x[Math-19]
+	_ = x[Comma-20]
+	_ = x[Colon-21]
+	_ = x[Asmt-22]
+	_ = x[Cmp-23]
+	_ = x[Unknown-24]
+	_ = x[EOF-25]
 }
 
-const _TokenType_name = "EOLIdenLiteralDirectionSpecDefaultIfElseSwitchLParenRParenLCurlyRCurlyLBraceRBraceAtmarkMathCommaColonAsmtCmpUnknown"
+const _TokenType_name = "EOLIdenLiteralDirectionSpecDefaultIfElseSwitchWaitWhileRepeatLParenRParenLCurlyRCurlyLBraceRBraceAtmarkMathCommaColonAsmtCmpUnknownEOF"
 
-var _TokenType_index = [...]uint8{0, 3, 7, 14, 23, 27, 34, 36, 40, 46, 52, 58, 64, 70, 76, 82, 88, 92, 97, 102, 106, 109, 116}
+var _TokenType_index = [...]uint8{0, 3, 7, 14, 23, 27, 34, 36, 40, 46, 50, 55, 61, 67, 73, 79, 85, 91, 97, 103, 107, 112, 117, 121, 124, 131, 134}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
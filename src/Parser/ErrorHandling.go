@@ -1,42 +1,140 @@
 package Parser
 
 import (
-	"fmt"
-	"os"
-	"runtime"
+	"strings"
 
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
 	L "github.com/ConnerTenn/Project-Chrono/Lexer"
 )
 
-func displayTokenError(t L.Token) {
+// The state of parsing a single file
+type parser struct {
+	diagnostics []D.Diagnostic // problems found while parsing the file
+}
+
+// raised to abandon parsing once an error has been found
+type bailout struct{}
 
-	fmt.Println("Unexpected Token: ", t)
+func (p *parser) displayTokenError(t L.Token) {
+	p.diagnostics = append(p.diagnostics, D.Errorf(D.UnexpectedToken, t.Pos, t.End(), "Unexpected Token %q", t.Value))
 
-	os.Exit(-1)
+	panic(bailout{})
 }
 
-func displayError(context string, recievedToken L.Token, expected ...L.TokenType) {
+func (p *parser) displayError(context string, recievedToken L.Token, expected ...L.TokenType) {
+	p.raiseError(D.UnexpectedToken, context, recievedToken, expected...)
+}
 
-	fmt.Println("Error Parsing:", context,
-		"\nRecieved:", recievedToken)
+// for errors in a token that is otherwise in the right place
+func (p *parser) displayTokenProblem(code D.Code, context string, recievedToken L.Token) {
+	p.raiseError(code, context, recievedToken)
+}
 
-	fmt.Print("Expected: ")
-	for _, expect := range expected {
-		fmt.Print(expect, " ")
+func (p *parser) displayAndCheckError(context string, recievedToken L.Token, expected ...L.TokenType) {
+	for _, token := range expected {
+		if recievedToken.GetType() == token {
+			return
+		}
 	}
-	fmt.Println()
 
-	os.Exit(-1)
+	p.raiseError(D.UnexpectedToken, context, recievedToken, expected...)
 }
 
-func displayAndCheckError(context string, recievedToken L.Token, expected ...L.TokenType) {
+// Consumes the next token if it is one of the expected types.
+// Otherwise the error is raised without consuming the token, so recovery can resume from it
+func (p *parser) expectToken(lex *L.Lexer, context string, expected ...L.TokenType) L.Token {
+	t := lex.PeekNext()
 	for _, token := range expected {
-		if recievedToken.GetType() == token {
+		if t.GetType() == token {
+			return lex.GetNext()
+		}
+	}
+
+	p.raiseError(D.UnexpectedToken, context, t, expected...)
+	return t
+}
+
+// records the error and abandons parsing
+func (p *parser) raiseError(code D.Code, context string, recievedToken L.Token, expected ...L.TokenType) {
+	diag := D.Errorf(code, recievedToken.Pos, recievedToken.End(),
+		"%s, recieved %q", context, recievedToken.Value)
+
+	if len(expected) > 0 {
+		var names []string
+		for _, expect := range expected {
+			names = append(names, expect.String())
+		}
+		diag = diag.WithNote("Expected: %s", strings.Join(names, " "))
+	}
+
+	// the same error can be raised again by each enclosing construct while recovering
+	if n := len(p.diagnostics); n > 0 && p.diagnostics[n-1].Start == diag.Start && p.diagnostics[n-1].Message == diag.Message {
+		panic(bailout{})
+	}
+
+	p.diagnostics = append(p.diagnostics, diag)
+
+	panic(bailout{})
+}
+
+// Runs parse, catching any error it raises.
+// Returns false if parsing was abandoned
+func recoverable(parse func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isBailout := r.(bailout); !isBailout {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+
+	parse()
+	return true
+}
+
+// Skips the remains of a statement after an error, so parsing can carry on from the next one.
+// Stops before a '}', a new line or any of the extra stop tokens, and after a ';'
+func synchronize(lex *L.Lexer, start L.Token, stop ...string) {
+	// make sure the offending token is dropped so the same error is not hit again
+	if t := lex.PeekNext(); t.Pos == start.Pos && !t.IsEOF() && !t.IsRCurly() {
+		lex.GetNext()
+	}
+
+	for t := lex.PeekNext(); !t.IsEOF(); t = lex.PeekNext() {
+		if t.IsRCurly() || t.LineStart {
+			return
+		}
+		for _, str := range stop {
+			if t.Is(str) {
+				return
+			}
+		}
+
+		lex.GetNext()
+		if t.Is(";") {
 			return
 		}
 	}
+}
 
-	_, fn, line, _ := runtime.Caller(1)
+// Skips the remains of a module after an error, up to the end of its block or
+// the start of the next module
+func synchronizeModule(lex *L.Lexer) {
+	depth := 0
+	for t := lex.PeekNext(); !t.IsEOF(); t = lex.PeekNext() {
+		if depth == 0 && t.LineStart && t.IsIden() {
+			return
+		}
 
-	displayError(fmt.Sprintf(context+" -- at  %s:%d", fn, line), recievedToken, expected...)
+		lex.GetNext()
+		if t.IsLCurly() {
+			depth++
+		} else if t.IsRCurly() {
+			depth--
+			if depth <= 0 {
+				return
+			}
+		}
+	}
 }
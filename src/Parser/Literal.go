@@ -0,0 +1,104 @@
+package Parser
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+	L "github.com/ConnerTenn/Project-Chrono/Lexer"
+)
+
+var literalBases = map[AST.LiteralKind]int{
+	AST.Decimal: 10,
+	AST.Hex:     16,
+	AST.Binary:  2,
+	AST.Octal:   8,
+}
+
+// Decodes a numeric literal.
+// Unsized literals are written as 255, 0xFF, 0b1111_1111 or 0o377.
+// Sized literals give the width first, as in 8'd255, 8'hFF, 8'b1111_1111 or 8'o377.
+// Underscores can be used anywhere after the first digit to separate digits.
+// The values of a switch can also use ? for bits that match anything, as in 4'b1??0
+func (p *parser) parseLiteral(t L.Token) *AST.Literal {
+	lit := &AST.Literal{Pos: t.Pos, Kind: AST.Decimal, Text: t.Value}
+	digits := t.Value
+
+	if idx := strings.IndexByte(digits, '\''); idx >= 0 {
+		width, ok := new(big.Int).SetString(strings.ReplaceAll(digits[:idx], "_", ""), 10)
+		if !ok || width.Sign() <= 0 || !width.IsInt64() {
+			p.displayTokenProblem(D.InvalidLiteral, "Invalid literal width", t)
+		}
+		lit.Width = int(width.Int64())
+
+		digits = digits[idx+1:]
+		if len(digits) == 0 {
+			p.displayTokenProblem(D.InvalidLiteral, "Sized literal is missing its base (d, h, b or o)", t)
+		}
+		switch digits[0] {
+		case 'd', 'D':
+			lit.Kind = AST.Decimal
+		case 'h', 'H':
+			lit.Kind = AST.Hex
+		case 'b', 'B':
+			lit.Kind = AST.Binary
+		case 'o', 'O':
+			lit.Kind = AST.Octal
+		default:
+			p.displayTokenProblem(D.InvalidLiteral, "Unknown literal base, expected d, h, b or o", t)
+		}
+		digits = digits[1:]
+
+	} else if len(digits) > 1 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			lit.Kind = AST.Hex
+			digits = digits[2:]
+		case 'b', 'B':
+			lit.Kind = AST.Binary
+			digits = digits[2:]
+		case 'o', 'O':
+			lit.Kind = AST.Octal
+			digits = digits[2:]
+		}
+	}
+
+	digits = strings.ReplaceAll(digits, "_", "")
+	if strings.ContainsRune(digits, '?') {
+		digits = p.parseDontCares(lit, digits, t)
+	}
+	value, ok := new(big.Int).SetString(digits, literalBases[lit.Kind])
+	if !ok || digits == "" || digits[0] == '+' || digits[0] == '-' {
+		p.displayTokenProblem(D.InvalidLiteral, "Invalid digits for a "+strings.ToLower(lit.Kind.String())+" literal", t)
+	}
+	lit.Value = value
+
+	if lit.Width > 0 && (value.BitLen() > lit.Width || (lit.Mask != nil && lit.Mask.BitLen() > lit.Width)) {
+		p.displayTokenProblem(D.LiteralOverflow, "Literal value does not fit within its width", t)
+	}
+
+	return lit
+}
+
+// Records the ? digits of a literal in its mask, returning the digits with each ? as a 0
+func (p *parser) parseDontCares(lit *AST.Literal, digits string, t L.Token) string {
+	if lit.Kind == AST.Decimal {
+		p.displayTokenProblem(D.InvalidLiteral, "Don't care bits need a binary, octal or hex literal", t)
+	}
+
+	//Each ? covers every bit of its digit
+	all := strconv.FormatInt(int64(literalBases[lit.Kind]-1), literalBases[lit.Kind])
+	var mask strings.Builder
+	for _, digit := range digits {
+		if digit == '?' {
+			mask.WriteString(all)
+		} else {
+			mask.WriteByte('0')
+		}
+	}
+	lit.Mask, _ = new(big.Int).SetString(mask.String(), literalBases[lit.Kind])
+
+	return strings.ReplaceAll(digits, "?", "0")
+}
@@ -1,43 +1,90 @@
 package Parser
 
 import (
-	"strconv"
+	"fmt"
+	"strings"
 
 	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
 	L "github.com/ConnerTenn/Project-Chrono/Lexer"
 )
 
 // entry parsing function
-func Parse(lex *L.Lexer) []AST.AST {
-	var tree []AST.AST
+func Parse(lex *L.Lexer) (tree []AST.AST, diags []D.Diagnostic) {
+	p := &parser{}
+
+	// errors abandon parsing, returning what was parsed up to that point
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+		}
+		diags = p.diagnostics
+		D.SetFile(diags, lex.FileName())
+	}()
 
 	for lex.NextExists() {
 		// dispatches to top level declarations
 		curToken := lex.GetNext()
 		//nextToken, _ := lex.PeekNext()
 
+		if curToken.Is("const") {
+			tree = append(tree, p.parseFileConst(lex, curToken))
+			continue
+		}
+
 		// FIXME: assume module
-		tree = append(tree, parseModule(lex, curToken))
+		tree = append(tree, p.parseModule(lex, curToken))
 
 	}
 
-	return tree
+	return tree, p.diagnostics
 }
 
-func parseModule(lex *L.Lexer, t L.Token) AST.ModuleDecl {
+// On an error, the module is returned as far as it was parsed
+func (p *parser) parseModule(lex *L.Lexer, t L.Token) AST.ModuleDecl {
 	newModule := AST.ModuleDecl{}
 
-	newModule.Name = parseIdent(t)
-	t = lex.GetNext()
+	if !recoverable(func() { p.parseModuleBody(lex, t, &newModule) }) {
+		synchronizeModule(lex)
+	}
+
+	return newModule
+}
+
+// On an error, the rest of the line is skipped
+func (p *parser) parseFileConst(lex *L.Lexer, t L.Token) *AST.ValueDecl {
+	decl := &AST.ValueDecl{}
+
+	if !recoverable(func() { *decl = p.parseConst(lex) }) {
+		synchronize(lex, t)
+	}
+
+	return decl
+}
+
+func (p *parser) parseModuleBody(lex *L.Lexer, t L.Token, newModule *AST.ModuleDecl) {
 
-	// TODO : Add BadExpr
-	displayAndCheckError("Did not find LParen to open module parameters", t, L.LParen)
+	newModule.Name = p.parseIdent(t)
+	p.expectToken(lex, "Did not find LParen to open module parameters", L.LParen)
 
 	// build parameters
 	for !lex.ExpectNext(")") {
-		t = lex.GetNext() //get parameters
+		// compile time parameters are listed among the ports
+		if lex.ExpectNext("const") {
+			lex.GetNext()
+			newModule.Consts = append(newModule.Consts, p.parseConst(lex))
+
+			if lex.ExpectNext(",") {
+				lex.GetNext() //drop comma
+			}
+			continue
+		}
+
+		t = p.expectToken(lex, "Parameter direction not found", L.Direction) //get parameters
 
-		newModule.Params = append(newModule.Params, parseParam(lex, t))
+		newModule.Params = append(newModule.Params, p.parseParam(lex, t))
 
 		if lex.ExpectNext(",") {
 			lex.GetNext() //drop comma
@@ -47,13 +94,10 @@ func parseModule(lex *L.Lexer, t L.Token) AST.ModuleDecl {
 
 	//FIXME: Bypass block
 
-	newModule.Block = parseBlock(lex)
-
-	return newModule
+	newModule.Block = p.parseBlock(lex)
 }
 
-// TODO: Split out a parseSignal function
-func parseParam(lex *L.Lexer, t L.Token) AST.ParamDecl {
+func (p *parser) parseParam(lex *L.Lexer, t L.Token) AST.ParamDecl {
 	curParam := AST.ParamDecl{}
 
 	// set / get direction
@@ -66,105 +110,254 @@ func parseParam(lex *L.Lexer, t L.Token) AST.ParamDecl {
 		curParam.Dir = AST.Inout
 	}
 
-	// set / get bit width
-	if lex.ExpectNext("[") {
-		lex.GetNext()
-		t = lex.GetNext()
+	// a signal being a register is decided by its clock, so latch is the only spec a port can have
+	latch := false
+	if lex.ExpectNextType(func(t L.Token) bool { return t.GetType() == L.Spec }) {
+		spec := lex.GetNext()
+		if !spec.Is("latch") {
+			p.displayTokenProblem(D.UnexpectedToken, "A port can only be declared as a latch", spec)
+		}
+		latch = true
+	}
+
+	curParam.SignalDecl = p.parseSignal(lex, latch)
 
-		displayAndCheckError("Bit width specifier not found", t, L.Literal)
+	return curParam
+}
 
-		curParam.Width, _ = strconv.Atoi(t.Value)
+// Parses the declaration of a signal after any leading keywords:
+// [width] Name @Clk = Init
+func (p *parser) parseSignal(lex *L.Lexer, latch bool) AST.SignalDecl {
+	curSignal := AST.SignalDecl{Latch: latch}
 
-		t = lex.GetNext()
+	// set / get bit width, which can be any constant expression
+	if lex.ExpectNext("[") {
+		lex.GetNext()
+		curSignal.WidthExpr = p.parseBinary(lex, AST.MinPrecedence)
 
-		displayAndCheckError("Bit width closing brace not found", t, L.RBrace)
+		p.expectToken(lex, "Bit width closing brace not found", L.RBrace)
 	}
 
 	// get / set name
-	t = lex.GetNext()
+	t := p.expectToken(lex, "Could not parse identifier", L.Iden)
 
-	curParam.Name = parseIdent(t)
+	curSignal.Name = p.parseIdent(t)
 
 	// check if tied to a clock
 	if lex.ExpectNext("@") {
 		// drop Atmark
-		_ = lex.GetNext()
+		at := lex.GetNext()
+		if curSignal.Latch {
+			p.displayTokenProblem(D.UnexpectedToken, "A latch can't have a clock", at)
+		}
 
-		curParam.Clock = &AST.ClockDecl{}
+		clk := p.parseClock(lex)
+		curSignal.Clock = &clk
+	}
 
-		// get clock info
+	// check for an initial value
+	if lex.ExpectNext("=") {
 		t = lex.GetNext()
 
-		displayAndCheckError("Clock Declaration Incorrect", t, L.Iden, L.Math)
-		if t.IsMath() {
-			if t.Is("!") {
-				curParam.Clock.Neg = true
+		if curSignal.Clock == nil {
+			p.displayError("Only registers can have an initial value", t, L.Atmark)
+		}
 
-				t = lex.GetNext()
-			} else {
-				displayError("Clocks Can Only Be Negated", t, L.Iden, L.Math)
-			}
+		curSignal.Init = p.ParseExpression(lex)
+	}
+
+	return curSignal
+}
+
+// Parses a constant after the const keyword:
+// [width] Name = Value
+func (p *parser) parseConst(lex *L.Lexer) AST.ValueDecl {
+	decl := AST.ValueDecl{}
+
+	if lex.ExpectNext("[") {
+		lex.GetNext()
+		decl.WidthExpr = p.parseBinary(lex, AST.MinPrecedence)
+
+		p.expectToken(lex, "Bit width closing brace not found", L.RBrace)
+	}
+
+	t := p.expectToken(lex, "Could not parse identifier", L.Iden)
+	decl.Name = p.parseIdent(t)
+
+	if !lex.ExpectNext("=") {
+		p.displayError(fmt.Sprintf("Constant %s needs a value", decl.Name.Name), lex.PeekNext(), L.Asmt)
+	}
+	lex.GetNext()
+
+	decl.Value = p.ParseExpression(lex)
+
+	return decl
+}
+
+// Parses an instance of a module after the module name:
+// Name(Port: Value, ...)
+func (p *parser) parseInstance(lex *L.Lexer, module L.Token) AST.InstanceDecl {
+	inst := AST.InstanceDecl{Module: p.parseIdent(module)}
+
+	t := p.expectToken(lex, "Could not parse identifier", L.Iden)
+	inst.Name = p.parseIdent(t)
+
+	open := p.expectToken(lex, "Did not find LParen to open the port connections", L.LParen)
+
+	for !lex.ExpectNext(")") {
+		port := p.parseIdent(p.expectToken(lex, "Expected the name of a port", L.Iden))
+		if !lex.ExpectNext(":") {
+			p.displayError(fmt.Sprintf("Expected : after port %s", port.Name), lex.PeekNext(), L.Colon)
 		}
+		lex.GetNext()
+		value := p.parseBinary(lex, AST.MinPrecedence)
 
-		displayAndCheckError("Clock Declaration Incorrect", t, L.Iden)
-		curParam.Clock.Name = parseIdent(t)
+		inst.Conns = append(inst.Conns, AST.PortConn{Port: port, Value: value})
+
+		if !lex.ExpectNext(",") {
+			break
+		}
+		lex.GetNext() //drop comma
 	}
+	p.expectToken(lex, fmt.Sprintf("Port connections at %d:%d are not closed", open.Pos[0], open.Pos[1]), L.RParen)
 
-	return curParam
+	return inst
 }
 
-func parseIdent(t L.Token) AST.Ident {
-	displayAndCheckError("Could not parse identifier", t, L.Iden)
+// Parses the clock following an '@', in either the @Clk or @(Clk) form
+func (p *parser) parseClock(lex *L.Lexer) AST.ClockDecl {
+	clk := AST.ClockDecl{}
+
+	paren := lex.ExpectNext("(")
+	if paren {
+		// drop LParen
+		_ = lex.GetNext()
+	}
+
+	// get clock info
+	if lex.ExpectNext("!") {
+		clk.Neg = true
+
+		lex.GetNext()
+	} else if lex.ExpectNextType(L.Token.IsOperator) {
+		p.displayError("Clocks Can Only Be Negated", lex.PeekNext(), L.Iden, L.Math)
+	}
+
+	t := p.expectToken(lex, "Clock Declaration Incorrect", L.Iden)
+	clk.Name = p.parseIdent(t)
+
+	if paren {
+		p.expectToken(lex, "Clock Declaration missing closing paren", L.RParen)
+	}
+
+	return clk
+}
+
+func (p *parser) parseIdent(t L.Token) AST.Ident {
+	p.displayAndCheckError("Could not parse identifier", t, L.Iden)
 
 	return AST.Ident{Pos: t.Pos, Name: t.Value}
 }
 
-func parseBlock(lex *L.Lexer) AST.BlockStmt {
-	t := lex.GetNext() //Consume LCurly
-	displayAndCheckError("Block Statement improperly started", t, L.LCurly)
+func (p *parser) parseBlock(lex *L.Lexer) AST.BlockStmt {
+	t := p.expectToken(lex, "Block Statement improperly started", L.LCurly)
 
 	blk := AST.BlockStmt{StartPos: t.Pos}
 
 	//Run until end of block
 	for t = lex.PeekNext(); !t.IsRCurly(); t = lex.PeekNext() {
+		if t.IsEOF() {
+			p.displayError("Block Statement not terminated", t, L.RCurly)
+		}
+
+		//Statements may optionally be terminated by a semicolon
+		if t.IsEOL() {
+			lex.GetNext()
+			continue
+		}
+
 		//FIXME : Assuming blocks contain only statements
-		blk.StmtList = append(blk.StmtList, parseStatement(lex))
+		blk.StmtList = append(blk.StmtList, p.parseStatement(lex))
 	}
-	displayAndCheckError("Block Statement improperly terminated", t, L.RCurly)
+	p.displayAndCheckError("Block Statement improperly terminated", t, L.RCurly)
 	lex.GetNext() //Consume RCurly
 
 	blk.EndPos = t.Pos
 	return blk
 }
 
+// On an error, the rest of the statement is skipped and replaced with a BadStmt
+func (p *parser) parseStatement(lex *L.Lexer) AST.Stmt {
+	var stmt AST.Stmt
+
+	start := lex.PeekNext()
+	if !recoverable(func() { stmt = p.parseStatementBody(lex) }) {
+		synchronize(lex, start)
+		stmt = &AST.BadStmt{Pos: start.Pos}
+	}
+
+	return stmt
+}
+
 //FIXME : Definitely a lot to be added here
-func parseStatement(lex *L.Lexer) AST.Stmt {
+func (p *parser) parseStatementBody(lex *L.Lexer) AST.Stmt {
 	next := lex.PeekNext()
-	displayAndCheckError("Invalid beginning of a statement", next, L.Iden, L.If, L.LCurly)
+	p.displayAndCheckError("Invalid beginning of a statement", next, L.Iden, L.If, L.Switch, L.Wait, L.While, L.Repeat, L.LCurly, L.Atmark, L.Spec)
+
+	if next.Is("sig") {
+		//Consume sig
+		sigToken := lex.GetNext()
 
-	if next.IsIden() {
-		//FIXME: Assume expression is an assignment
-		lhs := lex.GetNext()
+		//Latches must be asked for explicitly
+		latch := false
+		if lex.ExpectNext("latch") {
+			lex.GetNext()
+			latch = true
+		}
 
-		asmt := lex.GetNext()
-		displayAndCheckError("Expected assignment statement", asmt, L.Asmt)
+		decl := p.parseSignal(lex, latch)
+
+		return &AST.DeclStmt{Pos: sigToken.Pos, Decl: &decl}
+
+	} else if next.Is("const") {
+		//Consume const
+		constToken := lex.GetNext()
+
+		decl := p.parseConst(lex)
+
+		return &AST.DeclStmt{Pos: constToken.Pos, Decl: &decl}
+
+	} else if next.IsIden() {
+		lhsToken := lex.GetNext()
+
+		//A module name followed by the name of the instance
+		if lex.ExpectNextType(L.Token.IsIden) {
+			decl := p.parseInstance(lex, lhsToken)
+
+			return &AST.DeclStmt{Pos: lhsToken.Pos, Decl: &decl}
+		}
+
+		//Otherwise the expression is an assignment
+		lhs := p.parseSelect(lex, &AST.Ident{Pos: lhsToken.Pos, Name: lhsToken.Value})
+
+		asmt := p.expectToken(lex, "Expected assignment statement", L.Asmt)
 
 		op := AST.Asmt
 		if asmt.Is("<-") {
 			op = AST.AsmtReg
 		}
 
-		rhs := ParseExpression(lex)
+		rhs := p.ParseExpression(lex)
 
-		return &AST.AssignStmt{Pos: lhs.Pos, Op: op, LHS: &AST.Ident{Pos: lhs.Pos, Name: lhs.Value}, RHS: rhs}
+		return &AST.AssignStmt{Pos: lhsToken.Pos, Op: op, LHS: lhs, RHS: rhs}
 
 	} else if next.Is("if") {
 		//Consume if
 		ifToken := lex.GetNext()
 
-		cond := ParseExpression(lex)
+		cond := p.ParseExpression(lex)
 
-		body := parseBlock(lex)
+		body := p.parseBlock(lex)
 
 		newStmt := AST.IfStmt{
 			Pos:  ifToken.Pos,
@@ -178,163 +371,380 @@ func parseStatement(lex *L.Lexer) AST.Stmt {
 			_ = lex.GetNext()
 			t := lex.PeekNext()
 
-			displayAndCheckError("else cannot be an arbitrary statement", t, L.If, L.LCurly)
+			p.displayAndCheckError("else cannot be an arbitrary statement", t, L.If, L.LCurly)
+
+			newStmt.Else = p.parseStatement(lex)
+		}
 
-			newStmt.Else = parseStatement(lex)
+		return &newStmt
+	} else if next.Is("switch") {
+		return p.parseSwitch(lex)
+	} else if next.Is("wait") {
+		//Consume wait
+		waitToken := lex.GetNext()
+
+		return &AST.WaitStmt{Pos: waitToken.Pos, X: p.ParseExpression(lex)}
+	} else if next.Is("while") || next.Is("repeat") {
+		//Consume while or repeat
+		loopToken := lex.GetNext()
+
+		newStmt := AST.LoopStmt{Pos: loopToken.Pos}
+		if loopToken.Is("while") {
+			newStmt.Cond = p.ParseExpression(lex)
+		} else {
+			newStmt.Count = p.ParseExpression(lex)
 		}
 
+		body := p.parseBlock(lex)
+		newStmt.Body = &body
+
 		return &newStmt
 	} else if next.IsLCurly() {
-		newBlock := parseBlock(lex)
+		newBlock := p.parseBlock(lex)
 		return &newBlock
+	} else if next.Is("@") {
+		return p.parseSequence(lex)
 	} else {
+		//Any other spec, such as a latch without sig before it.
+		//Raising the error has the token skipped, so parsing can't get stuck on it
+		p.displayTokenProblem(D.UnexpectedToken, "Signals are declared with sig, as in sig latch [8] Held", next)
 		return &AST.BadStmt{Pos: next.Pos}
 	}
 }
 
-//fpn: Forward polish notation
-func createExpression(fpn chan L.Token) AST.Expr {
-	head := <-fpn
-	if head.IsOperator() || head.IsParen() {
-		op := parseOperation(head)
-		//Recursively collect the RHS
-		rhs := createExpression(fpn)
-		//Recursively collect the LHS
-		lhs := createExpression(fpn)
-		return &AST.MathExpr{Pos: head.Pos, LHS: lhs, RHS: rhs, Op: op}
-	}
-
-	//If this isn't an operation, it must be an Iden or a Literal
-	if head.IsIden() {
-		return &AST.Ident{Pos: head.Pos, Name: head.Value}
-	} else if head.IsLiteral() {
-		return &AST.Literal{Pos: head.Pos, Value: head.Value}
+// A switch over a selector, with an arm per line:
+// switch Sel { 0, 1: Stmt; 4'b1??0: { ... }; default: Stmt }
+func (p *parser) parseSwitch(lex *L.Lexer) AST.Stmt {
+	//Consume switch
+	switchToken := lex.GetNext()
+
+	newStmt := &AST.SwitchStmt{Pos: switchToken.Pos}
+	newStmt.X = p.ParseExpression(lex)
+
+	open := p.expectToken(lex, "Switch arms improperly started", L.LCurly)
+
+	var def *[2]int // Position of the default arm, once it has been found
+
+	for t := lex.PeekNext(); !t.IsRCurly(); t = lex.PeekNext() {
+		if t.IsEOF() {
+			p.displayError(fmt.Sprintf("Switch at %d:%d not terminated", open.Pos[0], open.Pos[1]), t, L.RCurly)
+		}
+
+		//Arms may optionally be terminated by a semicolon
+		if t.IsEOL() {
+			lex.GetNext()
+			continue
+		}
+
+		//A broken arm is skipped so the rest of the switch can still be parsed
+		var arm AST.CaseClause
+		parse := func() {
+			arm = p.parseCase(lex)
+			if arm.IsDefault() && def != nil {
+				p.displayTokenProblem(D.UnexpectedToken, fmt.Sprintf("Switch already has a default arm at %d:%d", def[0], def[1]), t)
+			}
+		}
+		if !recoverable(parse) {
+			synchronize(lex, t)
+			continue
+		}
+		if arm.IsDefault() {
+			def = &arm.Pos
+		}
+		newStmt.Cases = append(newStmt.Cases, arm)
+	}
+	lex.GetNext() //Consume RCurly
+
+	return newStmt
+}
+
+// A single arm of a switch, Values: Body
+func (p *parser) parseCase(lex *L.Lexer) AST.CaseClause {
+	t := lex.PeekNext()
+	arm := AST.CaseClause{Pos: t.Pos}
+
+	if t.Is("default") {
+		lex.GetNext()
+	} else {
+		arm.Values = append(arm.Values, p.parseCaseValue(lex))
+		for lex.ExpectNext(",") {
+			lex.GetNext()
+			arm.Values = append(arm.Values, p.parseCaseValue(lex))
+		}
+	}
+
+	if !lex.ExpectNext(":") {
+		p.displayError("Expected : after the values of a switch arm", lex.PeekNext(), L.Colon)
+	}
+	lex.GetNext()
+
+	if lex.ExpectNext("{") {
+		body := p.parseBlock(lex)
+		arm.Body = &body
 	} else {
-		return &AST.BadExpr{}
+		stmt := p.parseStatementBody(lex)
+		arm.Body = &AST.BlockStmt{StartPos: stmt.GetPos(), EndPos: stmt.GetPos(), StmtList: []AST.Stmt{stmt}}
 	}
+
+	return arm
 }
 
-func ParseExpression(lex *L.Lexer) AST.Expr {
-	//Reverse polish notation buffer
-	var rpn []L.Token
-	//Stack for storing the operators
-	var opStack []L.Token
-
-	expectNext := true
-	t := lex.GetNext()
-	for expectNext {
-		expectNext = false
-
-		if t.IsIden() {
-			rpn = append(rpn, t)
-
-		} else if t.IsLiteral() {
-			rpn = append(rpn, t)
-
-		} else if t.IsAssignment() {
-			//Assignments always go directly onto the opStack (Since it is the lowest precedence)
-			opStack = append(opStack, t)
-			expectNext = true
-
-		} else if t.IsLParen() {
-			//LParen always goes directly onto stack as a marker for when RParen is found
-			opStack = append(opStack, t)
-			expectNext = true
-
-		} else if t.IsRParen() {
-			//Place all operations till the previous LParen onto the rpn stack
-			op := opStack[len(opStack)-1]
-			for !op.IsLParen() {
-				//Pop off opStack and place into rpn
-				rpn = append(rpn, op)
-				opStack = opStack[:len(opStack)-1]
-
-				op = opStack[len(opStack)-1]
-			}
-			//Remove LParen from opStack
-			opStack = opStack[:len(opStack)-1]
-
-		} else if t.IsOperator() {
-			if len(opStack) > 0 {
-				op1 := parseOperation(opStack[len(opStack)-1])
-				op2 := parseOperation(t)
-				//If op on the opStack is higher (or equal) precedence, place into the rpn immediately
-				if op1 != AST.Bracket && OpCmp(op1, op2) >= 0 {
-					rpn = append(rpn, opStack[len(opStack)-1])
-					opStack = opStack[:len(opStack)-1]
-				}
-			}
-			opStack = append(opStack, t)
-			expectNext = true
+// Values of a switch can have don't care bits, which aren't allowed anywhere else
+func (p *parser) parseCaseValue(lex *L.Lexer) AST.Expr {
+	if t := lex.PeekNext(); t.IsLiteral() && strings.ContainsRune(t.Value, '?') {
+		lex.GetNext()
+		return p.parseLiteral(t)
+	}
+	return p.parseBinary(lex, AST.MinPrecedence)
+}
 
-		} else {
-			displayError("Unknown token", t, L.Iden, L.Literal, L.Asmt, L.LParen, L.RParen, L.Math, L.Cmp)
+// A sequential block, written as @(Clk) { ... }
+// The block can be preceded by clauses, in any order:
+// when Start, once or loop, busy Wire, done Wire
+// and followed by abort when Cond, with an optional block of cleanup steps
+func (p *parser) parseSequence(lex *L.Lexer) AST.Stmt {
+	//Consume Atmark
+	at := lex.GetNext()
+
+	newStmt := &AST.SequenceStmt{StartPos: at.Pos}
+	newStmt.Clk = p.parseClock(lex)
+
+	seen := map[string]L.Token{}
+	for t := lex.PeekNext(); t.Is("when") || t.Is("once") || t.Is("loop") || t.Is("busy") || t.Is("done"); t = lex.PeekNext() {
+		lex.GetNext()
+
+		//once and loop are the same clause
+		clause := t.Value
+		if clause == "loop" {
+			clause = "once"
+		}
+		if prev, ok := seen[clause]; ok {
+			p.displayTokenProblem(D.UnexpectedToken, fmt.Sprintf("Sequence already has %s at %d:%d", prev.Value, prev.Pos[0], prev.Pos[1]), t)
+		}
+		seen[clause] = t
+
+		switch t.Value {
+		case "when":
+			newStmt.Start = p.ParseExpression(lex)
+		case "once":
+			newStmt.Restart = AST.Once
+		case "loop":
+			newStmt.Restart = AST.Loop
+		case "busy":
+			id := p.parseIdent(p.expectToken(lex, "Expected the wire to drive with whether the sequence is busy", L.Iden))
+			newStmt.Busy = &id
+		case "done":
+			id := p.parseIdent(p.expectToken(lex, "Expected the wire to drive with whether the sequence is done", L.Iden))
+			newStmt.Done = &id
 		}
+	}
 
-		if !expectNext {
-			//Check the next token to see if a new operator exists to continue the expression
-			n := lex.PeekNext()
-			if n.IsOperator() || n.IsRParen() {
-				expectNext = true
-			}
+	inner := p.parseBlock(lex)
+	newStmt.EndPos = inner.EndPos
+	newStmt.Inner = &inner
+
+	if lex.ExpectNext("abort") {
+		//Consume abort
+		lex.GetNext()
+		if !lex.ExpectNext("when") {
+			p.displayTokenProblem(D.UnexpectedToken, "Expected when after abort", lex.PeekNext())
 		}
+		lex.GetNext()
+		newStmt.Abort = p.ParseExpression(lex)
 
-		//Collect the next token if required
-		if expectNext {
-			t = lex.GetNext()
+		if lex.ExpectNext("{") {
+			cleanup := p.parseBlock(lex)
+			newStmt.EndPos = cleanup.EndPos
+			newStmt.Cleanup = &cleanup
 		}
 	}
 
-	for i := len(opStack) - 1; i >= 0; i-- {
-		rpn = append(rpn, opStack[i])
+	return newStmt
+}
+
+// On an error, the rest of the expression is skipped and replaced with a BadExpr
+func (p *parser) ParseExpression(lex *L.Lexer) AST.Expr {
+	var expr AST.Expr
+
+	start := lex.PeekNext()
+	if !recoverable(func() { expr = p.parseBinary(lex, AST.MinPrecedence) }) {
+		//Stop before the body of a block so it can still be parsed
+		synchronize(lex, start, "{")
+		expr = &AST.BadExpr{Pos: start.Pos}
 	}
 
-	// fmt.Println(rpn)
+	return expr
+}
+
+// Precedence climbing parser.
+// Collects operations that bind at least as tightly as minPrec
+func (p *parser) parseBinary(lex *L.Lexer, minPrec int) AST.Expr {
+	lhs := p.parseUnary(lex)
+
+	for {
+		t := lex.PeekNext()
+		op, ok := binaryOperations[t.Value]
+		if !ok || AST.Precedence[op] < minPrec {
+			return lhs
+		}
+		lex.GetNext()
+
+		//Left associative operations stop the RHS at an operation of the same precedence
+		nextPrec := AST.Precedence[op] + 1
+		if AST.Associativity[op] == AST.RightAssoc {
+			nextPrec = AST.Precedence[op]
+		}
+
+		rhs := p.parseBinary(lex, nextPrec)
+		lhs = &AST.MathExpr{Pos: t.Pos, LHS: lhs, RHS: rhs, Op: op}
+	}
+}
 
-	//Convert rpn into a forward polish notation as a channel acting as a fifo
-	fpn := make(chan L.Token, len(rpn))
-	for i := len(rpn) - 1; i >= 0; i-- {
-		fpn <- rpn[i]
+func (p *parser) parseUnary(lex *L.Lexer) AST.Expr {
+	t := lex.PeekNext()
+	if op, ok := unaryOperations[t.Value]; ok {
+		lex.GetNext()
+		return &AST.UnaryExpr{Pos: t.Pos, Op: op, X: p.parseUnary(lex)}
 	}
 
-	return createExpression(fpn)
+	return p.parsePrimary(lex)
 }
 
-func parseOperation(t L.Token) AST.Operation {
-	if !(t.IsOperator() || t.IsParen()) {
-		displayError("Expected operator in equation", t, L.Math)
+// A value or a parenthesized expression
+func (p *parser) parsePrimary(lex *L.Lexer) AST.Expr {
+	t := lex.PeekNext()
+
+	if t.IsIden() {
+		lex.GetNext()
+		if lex.ExpectNext("(") {
+			return p.parseCall(lex, t)
+		}
+		return p.parseSelect(lex, &AST.Ident{Pos: t.Pos, Name: t.Value})
+
+	} else if t.IsLiteral() {
+		lex.GetNext()
+		if strings.ContainsRune(t.Value, '?') {
+			p.displayTokenProblem(D.InvalidLiteral, "Don't care bits can only be used in the values of a switch", t)
+		}
+		return p.parseLiteral(t)
+
+	} else if t.IsLParen() {
+		lex.GetNext()
+		inner := p.parseBinary(lex, AST.MinPrecedence)
+		end := p.expectToken(lex, fmt.Sprintf("Unbalanced parentheses, ( at %d:%d is not closed", t.Pos[0], t.Pos[1]), L.RParen)
+		return &AST.ParenExpr{StartPos: t.Pos, EndPos: end.Pos, X: inner}
+
+	} else if t.IsLCurly() {
+		return p.parseConcat(lex)
 	}
 
-	var op AST.Operation
+	if t.IsOperator() {
+		p.displayError("Operator is missing a value", t, L.Iden, L.Literal, L.LParen)
+	}
+	p.displayError("Expected a value in expression", t, L.Iden, L.Literal, L.LParen, L.LCurly)
+	return nil
+}
 
-	switch t.Value {
-	case "=":
-		op = AST.Asmt
-	case "<=":
-		op = AST.Asmt
-	case "+":
-		op = AST.Add
-	case "-":
-		op = AST.Sub
-	case "*":
-		op = AST.Multi
-	case "/":
-		op = AST.Div
-	case "<<":
-		op = AST.LShift
-	case ">>":
-		op = AST.RShift
-	case "(":
-		op = AST.Bracket
-	case ")":
-		op = AST.Bracket
-	case "==":
-		op = AST.Equals
-	}
-
-	return op
+// An optional bit select or slice following a signal
+func (p *parser) parseSelect(lex *L.Lexer, x AST.Expr) AST.Expr {
+	if !lex.ExpectNext("[") {
+		return x
+	}
+	open := lex.GetNext()
+
+	left := p.parseBinary(lex, AST.MinPrecedence)
+
+	var expr AST.Expr
+	if lex.ExpectNextType(func(t L.Token) bool { return t.GetType() == L.Colon }) {
+		slice := &AST.SliceExpr{Pos: open.Pos, X: x, Left: left}
+		switch lex.GetNext().Value {
+		case "+:":
+			slice.Kind = AST.SliceUp
+		case "-:":
+			slice.Kind = AST.SliceDown
+		default:
+			slice.Kind = AST.SliceRange
+		}
+		slice.Right = p.parseBinary(lex, AST.MinPrecedence)
+		expr = slice
+	} else {
+		expr = &AST.IndexExpr{Pos: open.Pos, X: x, Index: left}
+	}
+
+	p.expectToken(lex, fmt.Sprintf("Bit select at %d:%d is not closed", open.Pos[0], open.Pos[1]), L.RBrace)
+	return expr
+}
+
+// The arguments of a call to the function named by fn
+func (p *parser) parseCall(lex *L.Lexer, fn L.Token) AST.Expr {
+	open := lex.GetNext()
+	call := &AST.CallExpr{Pos: fn.Pos, Fn: fn.Value}
+
+	if !lex.ExpectNext(")") {
+		call.Args = append(call.Args, p.parseBinary(lex, AST.MinPrecedence))
+		for lex.ExpectNext(",") {
+			lex.GetNext()
+			call.Args = append(call.Args, p.parseBinary(lex, AST.MinPrecedence))
+		}
+	}
+
+	p.expectToken(lex, fmt.Sprintf("Call to %s at %d:%d is not closed", fn.Value, open.Pos[0], open.Pos[1]), L.RParen)
+	return call
+}
+
+// A concatenation {A, B} or a replication {Count{A}}
+func (p *parser) parseConcat(lex *L.Lexer) AST.Expr {
+	open := lex.GetNext()
+
+	first := p.parseBinary(lex, AST.MinPrecedence)
+
+	if lex.ExpectNext("{") {
+		inner := p.parseConcat(lex)
+		end := p.expectToken(lex, fmt.Sprintf("Replication at %d:%d is not closed", open.Pos[0], open.Pos[1]), L.RCurly)
+		return &AST.ReplicateExpr{StartPos: open.Pos, EndPos: end.Pos, Count: first, X: inner}
+	}
+
+	concat := &AST.ConcatExpr{StartPos: open.Pos, Parts: []AST.Expr{first}}
+	for lex.ExpectNext(",") {
+		lex.GetNext()
+		concat.Parts = append(concat.Parts, p.parseBinary(lex, AST.MinPrecedence))
+	}
+
+	end := p.expectToken(lex, fmt.Sprintf("Concatenation at %d:%d is not closed", open.Pos[0], open.Pos[1]), L.RCurly)
+	concat.EndPos = end.Pos
+	return concat
+}
+
+// Operators that go between two values
+var binaryOperations = map[string]AST.Operation{
+	"+":   AST.Add,
+	"-":   AST.Sub,
+	"*":   AST.Multi,
+	"/":   AST.Div,
+	"%":   AST.Mod,
+	"<<":  AST.LShift,
+	">>":  AST.RShift,
+	"<<<": AST.ArithLShift,
+	">>>": AST.ArithRShift,
+	"==":  AST.Equals,
+	"!=":  AST.NotEquals,
+	"<":   AST.Less,
+	"<=":  AST.LessEq,
+	">":   AST.Greater,
+	">=":  AST.GreaterEq,
+	"&":   AST.BitAnd,
+	"|":   AST.BitOr,
+	"^":   AST.BitXor,
+	"&&":  AST.LogicAnd,
+	"||":  AST.LogicOr,
 }
 
-func OpCmp(op1 AST.Operation, op2 AST.Operation) int {
-	return AST.Precedence[op1] - AST.Precedence[op2]
+// Operators that go before a value
+var unaryOperations = map[string]AST.Operation{
+	"-": AST.Negate,
+	"!": AST.Not,
+	"~": AST.BitNot,
+	"&": AST.ReduceAnd,
+	"|": AST.ReduceOr,
+	"^": AST.ReduceXor,
 }
@@ -0,0 +1,130 @@
+package Semantic
+
+import (
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// An abort skips the rest of the steps, so registers can be left with values that the sequence
+// never finishes with. Req <- 1; wait Ack; Req <- 0 always finishes with Req at 0,
+// but aborting during the wait leaves it at 1 unless the cleanup sets it again.
+//
+// Only constants assigned unconditionally by a step are tracked. Anything assigned under a condition,
+// by a loop or by a nested sequence could hold any value, so it isn't reported
+
+// A register as left by a step of a sequence
+type heldValue struct {
+	Lit  *AST.Literal // nil if it can't be known
+	Asmt *AST.AssignStmt
+}
+
+// Warns about registers an abort can leave with a value the sequence doesn't otherwise finish with
+func (c *checker) checkAbort(seq *AST.SequenceStmt) {
+	if seq.Abort == nil {
+		return
+	}
+
+	//Registers the cleanup sets are assumed to be put right by it
+	cleaned := map[*AST.Object]bool{}
+	if seq.Cleanup != nil {
+		for _, asmt := range stepAssignments(seq.Cleanup) {
+			if id := assignTarget(asmt.LHS); id != nil && id.Obj != nil {
+				cleaned[id.Obj] = true
+			}
+		}
+	}
+
+	//The value of each register after every step, in the order they are first assigned
+	var order []*AST.Object
+	held := map[*AST.Object]heldValue{}
+	var after []map[*AST.Object]heldValue
+	for _, step := range steps(seq.Inner) {
+		values := heldAfter(step)
+		for _, asmt := range stepAssignments(step) {
+			id := assignTarget(asmt.LHS)
+			if id == nil || id.Obj == nil {
+				continue
+			}
+			if _, ok := held[id.Obj]; !ok {
+				order = append(order, id.Obj)
+			}
+			held[id.Obj] = values[id.Obj]
+		}
+
+		snapshot := map[*AST.Object]heldValue{}
+		for obj, value := range held {
+			snapshot[obj] = value
+		}
+		after = append(after, snapshot)
+	}
+
+	for _, obj := range order {
+		final := held[obj]
+		if final.Lit == nil || cleaned[obj] {
+			continue
+		}
+
+		//Aborting during a step skips it, leaving what the steps before it set
+		for n, step := range steps(seq.Inner)[1:] {
+			value, ok := after[n][obj]
+			if !ok || value.Lit == nil || value.Lit.Value.Cmp(final.Lit.Value) == 0 {
+				continue
+			}
+
+			stepPos := step.GetPos()
+			finalPos := final.Asmt.Pos
+			c.report(D.Warningf(D.AbortedRegister, seq.Abort.GetPos(), seq.Abort.GetPos(),
+				"Aborting during the step at %d:%d leaves %s at %s, which the sequence never finishes with", stepPos[0], stepPos[1], obj.Name, value.Lit.Text).
+				WithNote("%s is set to %s at %d:%d before the sequence finishes; set it in the cleanup of the abort", obj.Name, final.Lit.Text, finalPos[0], finalPos[1]))
+			break
+		}
+	}
+}
+
+// The registers a step assigns, with the constant they are left at when it is known
+func heldAfter(step AST.Stmt) map[*AST.Object]heldValue {
+	values := map[*AST.Object]heldValue{}
+
+	var visit func(stmt AST.Stmt)
+	visit = func(stmt AST.Stmt) {
+		switch obj := stmt.(type) {
+		case *AST.AssignStmt:
+			id := assignTarget(obj.LHS)
+			if id == nil || id.Obj == nil {
+				break
+			}
+			//Assigning part of a register leaves the rest of it as it was
+			lit, _ := obj.RHS.(*AST.Literal)
+			if _, whole := obj.LHS.(*AST.Ident); !whole {
+				lit = nil
+			}
+			values[id.Obj] = heldValue{Lit: lit, Asmt: obj}
+
+		case *AST.BlockStmt:
+			for _, inner := range obj.StmtList {
+				visit(inner)
+			}
+
+		default:
+			for _, asmt := range stepAssignments(stmt) {
+				if id := assignTarget(asmt.LHS); id != nil && id.Obj != nil {
+					values[id.Obj] = heldValue{Asmt: asmt}
+				}
+			}
+		}
+	}
+	visit(step)
+
+	return values
+}
+
+// Every assignment made within a statement, including by nested sequences
+func stepAssignments(stmt AST.Stmt) []*AST.AssignStmt {
+	var list []*AST.AssignStmt
+	eachStmt(stmt, func(inner AST.Stmt) {
+		if asmt, ok := inner.(*AST.AssignStmt); ok {
+			list = append(list, asmt)
+		}
+	})
+	return list
+}
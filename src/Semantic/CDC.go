@@ -0,0 +1,311 @@
+package Semantic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// A clock domain crossing is a register that reads a register of another clock, either directly
+// or through combinational logic. The edge of a clock doesn't change its domain.
+// Inputs have no known clock, so they aren't treated as crossings.
+//
+// The approved ways to cross are:
+//   - a single bit through a chain of at least two registers with nothing but the chain reading the first one
+//   - any value captured by a register only while a synchronized enable is set
+
+type crossingKind int
+
+const (
+	synchronized      crossingKind = iota // Single bit through a synchronizer chain
+	enableCaptured                        // Captured under a synchronized enable
+	multiBitSync                          // Several bits through a synchronizer chain
+	logicBeforeSync                       // Combinational logic in front of a synchronizer
+	unsynchronizedBit                     // A single bit with no synchronizer
+	unsynchronizedBus                     // Several bits with no synchronizer
+)
+
+var crossingText = map[crossingKind]string{
+	synchronized:      "synchronized",
+	enableCaptured:    "captured under a synchronized enable",
+	multiBitSync:      "UNSAFE, multi-bit value through a synchronizer",
+	logicBeforeSync:   "UNSAFE, combinational logic before the synchronizer",
+	unsynchronizedBit: "UNSAFE, no synchronizer",
+	unsynchronizedBus: "UNSAFE, multi-bit value with no synchronizer",
+}
+
+// A value passing from a register in one domain into a register of another
+type crossing struct {
+	From   *AST.Object // The register the value comes from
+	Via    *AST.Ident  // The signal read by the assignment, a wire if the value passes through logic
+	To     *driver
+	Bits   int
+	Kind   crossingKind
+	Detail string
+}
+
+// Finds every clock domain crossing, reporting the unsafe ones.
+// Returns a report of every module's crossings for review
+func CheckCDC(tree []AST.AST) (string, []D.Diagnostic) {
+	c := &checker{}
+	var report strings.Builder
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			var drivers []*driver
+			collectDrivers(&mod.Block, nil, nil, false, &drivers)
+			crossings := findCrossings(drivers)
+			c.reportCrossings(crossings)
+			writeCDCReport(&report, mod, drivers, crossings)
+		}
+	}
+
+	return report.String(), c.diagnostics
+}
+
+// The clock a signal is synchronous to, or nil for wires and inputs
+func domainOf(obj *AST.Object) *AST.Object {
+	if decl := obj.Signal(); decl != nil && decl.Clock != nil {
+		return decl.Clock.Name.Obj
+	}
+	return nil
+}
+
+// Indexes the drivers of a module by the signal they assign
+type driverMap map[*AST.Object][]*driver
+
+func mapDrivers(drivers []*driver) driverMap {
+	byTarget := driverMap{}
+	for _, drv := range drivers {
+		byTarget[drv.Target.Obj] = append(byTarget[drv.Target.Obj], drv)
+	}
+	return byTarget
+}
+
+// The registers a wire's value comes from, through any amount of combinational logic
+func (drivers driverMap) sources(wire *AST.Object, seen map[*AST.Object]bool) []*AST.Object {
+	if seen[wire] {
+		return nil
+	}
+	seen[wire] = true
+
+	var regs []*AST.Object
+	for _, drv := range drivers[wire] {
+		for _, read := range drv.Reads {
+			if domainOf(read.Ident.Obj) != nil {
+				regs = append(regs, read.Ident.Obj)
+			} else {
+				regs = append(regs, drivers.sources(read.Ident.Obj, seen)...)
+			}
+		}
+	}
+	return regs
+}
+
+// The value assigned, if it is a copy of a single signal
+func copiedSignal(drv *driver) *AST.Ident {
+	switch obj := stripParens(drv.Asmt.RHS).(type) {
+	case *AST.Ident:
+		return obj
+	case *AST.IndexExpr:
+		if id, ok := obj.X.(*AST.Ident); ok {
+			return id
+		}
+	}
+	return nil
+}
+
+// The first register of a synchronizer chain is only assigned once, unconditionally,
+// as a copy of a signal
+func syncStage(drivers driverMap, reg *AST.Object) *AST.Ident {
+	list := drivers[reg]
+	if len(list) != 1 || list[0].Conditional || list[0].Asmt.Op != AST.AsmtReg {
+		return nil
+	}
+	return copiedSignal(list[0])
+}
+
+// The register copying the first stage of a synchronizer, if it is the only thing reading it
+func nextStage(drivers []*driver, byTarget driverMap, first *AST.Object) *AST.Object {
+	var next *AST.Object
+	for _, drv := range drivers {
+		for _, read := range drv.Reads {
+			if read.Ident.Obj != first {
+				continue
+			}
+			src := syncStage(byTarget, drv.Target.Obj)
+			if next != nil || src == nil || src.Obj != first || domainOf(drv.Target.Obj) != domainOf(first) {
+				return nil
+			}
+			next = drv.Target.Obj
+		}
+	}
+	return next
+}
+
+func findCrossings(drivers []*driver) []*crossing {
+	byTarget := mapDrivers(drivers)
+
+	//Registers holding a synchronized value, which can be used to capture other values
+	synced := map[*AST.Object]bool{}
+	for reg := range byTarget {
+		src := syncStage(byTarget, reg)
+		if src == nil || domainOf(reg) == nil || signalWidth(reg.Signal()) != 1 {
+			continue
+		}
+		from := domainOf(src.Obj)
+		if from != nil && from != domainOf(reg) {
+			if next := nextStage(drivers, byTarget, reg); next != nil {
+				synced[next] = true
+			}
+		}
+	}
+
+	var crossings []*crossing
+	seen := map[[2]*AST.Object]bool{}
+	for _, drv := range drivers {
+		to := domainOf(drv.Target.Obj)
+		if to == nil || drv.Asmt.Op != AST.AsmtReg {
+			continue
+		}
+
+		for _, read := range drv.Reads {
+			from := []*AST.Object{read.Ident.Obj}
+			if domainOf(read.Ident.Obj) == nil {
+				from = byTarget.sources(read.Ident.Obj, map[*AST.Object]bool{})
+			}
+
+			for _, src := range from {
+				key := [2]*AST.Object{src, drv.Target.Obj}
+				if domainOf(src) == to || seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				bits := signalWidth(src.Signal())
+				if read.Bits.Known && read.Ident.Obj == src {
+					bits = read.Bits.High - read.Bits.Low + 1
+				}
+				cross := &crossing{From: src, Via: read.Ident, To: drv, Bits: bits}
+				classify(cross, drivers, byTarget, synced)
+				crossings = append(crossings, cross)
+			}
+		}
+	}
+
+	return crossings
+}
+
+func classify(cross *crossing, drivers []*driver, byTarget driverMap, synced map[*AST.Object]bool) {
+	drv := cross.To
+	reg := drv.Target.Obj
+
+	if copied := syncStage(byTarget, reg); copied != nil && copied.Obj == cross.Via.Obj {
+		if next := nextStage(drivers, byTarget, reg); next != nil {
+			cross.Detail = fmt.Sprintf("synchronizer %s -> %s", drv.Target.Name, next.Name)
+			switch {
+			case cross.Via.Obj != cross.From:
+				cross.Kind = logicBeforeSync
+			case cross.Bits > 1:
+				cross.Kind = multiBitSync
+			default:
+				cross.Kind = synchronized
+			}
+			return
+		}
+		cross.Detail = fmt.Sprintf("%s is a single register, a synchronizer needs a second", drv.Target.Name)
+	}
+
+	//A value is only captured once it has settled if the enable is synchronized.
+	//That doesn't help if the value is the enable
+	control := false
+	for _, cond := range drv.Conds {
+		control = control || cond.Ident == cross.Via
+	}
+	for _, cond := range drv.Conds {
+		if synced[cond.Ident.Obj] && !control {
+			cross.Kind = enableCaptured
+			cross.Detail = fmt.Sprintf("captured when %s is set", cond.Ident.Name)
+			return
+		}
+	}
+
+	if cross.Bits > 1 {
+		cross.Kind = unsynchronizedBus
+	} else {
+		cross.Kind = unsynchronizedBit
+	}
+}
+
+func (c *checker) reportCrossings(crossings []*crossing) {
+	for _, cross := range crossings {
+		if cross.Kind == synchronized || cross.Kind == enableCaptured {
+			continue
+		}
+
+		id := cross.Via
+		code := D.UnsyncCrossing
+		switch cross.Kind {
+		case multiBitSync:
+			code = D.MultiBitSync
+		case logicBeforeSync:
+			code = D.LogicBeforeSync
+		}
+
+		diag := D.Warningf(code, id.Pos, identEnd(id), "%s crosses from %s to %s: %s",
+			cross.From.Name, domainOf(cross.From).Name, domainOf(cross.To.Target.Obj).Name, crossingText[cross.Kind])
+		if cross.Detail != "" {
+			diag = diag.WithNote("%s", cross.Detail)
+		}
+		if cross.Via.Obj != cross.From {
+			diag = diag.WithNote("%s is read through %s", cross.From.Name, cross.Via.Name)
+		}
+		c.report(diag)
+	}
+}
+
+func writeCDCReport(out *strings.Builder, mod AST.ModuleDecl, drivers []*driver, crossings []*crossing) {
+	fmt.Fprintf(out, "CDC report for %s\n", mod.Name.Name)
+
+	var domains []string
+	seen := map[*AST.Object]bool{}
+	for _, drv := range drivers {
+		if clk := domainOf(drv.Target.Obj); clk != nil && !seen[clk] {
+			seen[clk] = true
+			domains = append(domains, clk.Name)
+		}
+	}
+	sort.Strings(domains)
+	if len(domains) == 0 {
+		domains = []string{"none"}
+	}
+	fmt.Fprintf(out, "  Clock domains: %s\n", strings.Join(domains, ", "))
+
+	if len(crossings) == 0 {
+		out.WriteString("  No clock domain crossings\n\n")
+		return
+	}
+
+	unsafe := 0
+	for _, cross := range crossings {
+		pos := cross.Via.Pos
+		fmt.Fprintf(out, "  %d:%d  %s -> %s  %s -> %s (%d bit", pos[0], pos[1],
+			domainOf(cross.From).Name, domainOf(cross.To.Target.Obj).Name, cross.From.Name, cross.To.Target.Name, cross.Bits)
+		if cross.Bits > 1 {
+			out.WriteString("s")
+		}
+		fmt.Fprintf(out, ")  %s", crossingText[cross.Kind])
+		if cross.Detail != "" {
+			fmt.Fprintf(out, ", %s", cross.Detail)
+		}
+		out.WriteString("\n")
+
+		if cross.Kind != synchronized && cross.Kind != enableCaptured {
+			unsafe++
+		}
+	}
+	fmt.Fprintf(out, "  %d crossings, %d unsafe\n\n", len(crossings), unsafe)
+}
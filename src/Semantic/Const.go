@@ -0,0 +1,244 @@
+package Semantic
+
+import (
+	"math/big"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Anything known at compile time is evaluated before the other checks run:
+// constant expressions are replaced by their value, widths are evaluated,
+// and an if statement with a constant condition is replaced by the branch it takes
+
+// Widest signal that can be declared
+const maxWidth = 1 << 24
+
+// Returns the tree with a module added for each specialization of a module with parameters
+func FoldConstants(tree []AST.AST) ([]AST.AST, []D.Diagnostic) {
+	c := &checker{}
+	c.startSpecializing(tree)
+
+	for _, elem := range tree {
+		switch obj := elem.(type) {
+		case *AST.ValueDecl:
+			c.foldValue(obj)
+		case AST.ModuleDecl:
+			c.foldModule(&obj)
+		}
+	}
+
+	return append(tree, c.specialized...), c.diagnostics
+}
+
+func (c *checker) foldModule(mod *AST.ModuleDecl) {
+	for i := range mod.Consts {
+		c.foldValue(&mod.Consts[i])
+	}
+	for i := range mod.Params {
+		c.foldSignal(&mod.Params[i].SignalDecl)
+	}
+	c.foldStmt(&mod.Block)
+}
+
+func (c *checker) foldSignal(decl *AST.SignalDecl) {
+	if decl.WidthExpr != nil {
+		decl.WidthExpr, decl.Width = c.foldWidth(decl.Name.Name, decl.WidthExpr)
+	}
+
+	if decl.Init != nil {
+		decl.Init = c.foldExpr(decl.Init)
+	}
+}
+
+// Constants must have a value known at compile time, which fits in their width if they have one
+func (c *checker) foldValue(decl *AST.ValueDecl) {
+	if decl.WidthExpr != nil {
+		decl.WidthExpr, decl.Width = c.foldWidth(decl.Name.Name, decl.WidthExpr)
+	}
+
+	reported := len(c.diagnostics)
+	decl.Value = c.foldExpr(decl.Value)
+
+	lit, ok := decl.Value.(*AST.Literal)
+	pos := decl.Value.GetPos()
+	switch {
+	case !ok && len(c.diagnostics) > reported:
+		//Couldn't be evaluated, which has already been reported
+	case !ok:
+		c.report(D.Errorf(D.NotConstant, pos, pos, "The value of %s must be a constant", decl.Name.Name))
+	case decl.Width > 0 && (lit.Value.Sign() < 0 || lit.Value.BitLen() > decl.Width):
+		c.report(D.Errorf(D.ValueOverflow, pos, pos, "%s does not fit in %s, which is %d bits wide", lit.Value, decl.Name.Name, decl.Width))
+	}
+}
+
+// Evaluates the width of a signal or constant, returning 0 if it isn't valid
+func (c *checker) foldWidth(name string, expr AST.Expr) (AST.Expr, int) {
+	reported := len(c.diagnostics)
+	expr = c.foldExpr(expr)
+
+	lit, ok := expr.(*AST.Literal)
+	pos := expr.GetPos()
+	switch {
+	case !ok && len(c.diagnostics) > reported:
+		//Couldn't be evaluated, which has already been reported
+	case !ok:
+		c.report(D.Errorf(D.NotConstant, pos, pos, "The width of %s must be a constant", name))
+	case lit.Value.Cmp(big.NewInt(1)) < 0 || lit.Value.Cmp(big.NewInt(maxWidth)) > 0:
+		c.report(D.Errorf(D.InvalidWidth, pos, pos, "The width of %s must be between 1 and %d, not %s",
+			name, maxWidth, lit.Value))
+	default:
+		return expr, int(lit.Value.Int64())
+	}
+	return expr, 0
+}
+
+// Folds the constants within a statement, returning what should replace it
+func (c *checker) foldStmt(stmt AST.Stmt) AST.Stmt {
+	switch obj := stmt.(type) {
+	case *AST.DeclStmt:
+		switch decl := obj.Decl.(type) {
+		case *AST.SignalDecl:
+			c.foldSignal(decl)
+		case *AST.ValueDecl:
+			c.foldValue(decl)
+		case *AST.InstanceDecl:
+			c.foldInstance(decl)
+		}
+
+	case *AST.AssignStmt:
+		if id := assignTarget(obj.LHS); id != nil && id.Obj != nil && id.Obj.Kind == AST.Const {
+			pos := id.Obj.GetPos()
+			c.report(D.Errorf(D.ConstantMisuse, id.Pos, identEnd(id), "%s is a constant, so it can't be assigned", id.Name).
+				WithNote("%s is declared at %d:%d", id.Name, pos[0], pos[1]))
+			return stmt
+		}
+		obj.LHS = c.foldExpr(obj.LHS)
+		obj.RHS = c.foldExpr(obj.RHS)
+
+	case *AST.IfStmt:
+		obj.Cond = c.foldExpr(obj.Cond)
+		if lit, ok := obj.Cond.(*AST.Literal); ok {
+			if lit.Value.Sign() != 0 {
+				return c.foldStmt(obj.Body)
+			}
+			if obj.Else != nil {
+				return c.foldStmt(obj.Else)
+			}
+			//Inside a sequence the statement still takes a step
+			return &AST.BlockStmt{StartPos: obj.Pos, EndPos: obj.Pos}
+		}
+
+		obj.Body = c.foldStmt(obj.Body)
+		if obj.Else != nil {
+			obj.Else = c.foldStmt(obj.Else)
+		}
+
+	case *AST.SwitchStmt:
+		return c.foldSwitch(obj)
+
+	case *AST.WaitStmt:
+		c.foldWait(obj)
+
+	case *AST.LoopStmt:
+		c.foldLoop(obj)
+
+	case *AST.BlockStmt:
+		for i, inner := range obj.StmtList {
+			obj.StmtList[i] = c.foldStmt(inner)
+		}
+
+	case *AST.SequenceStmt:
+		if obj.Start != nil {
+			obj.Start = c.foldExpr(obj.Start)
+		}
+		obj.Inner = c.foldStmt(obj.Inner)
+		if obj.Abort != nil {
+			obj.Abort = c.foldExpr(obj.Abort)
+		}
+		if obj.Cleanup != nil {
+			obj.Cleanup = c.foldStmt(obj.Cleanup)
+		}
+	}
+
+	return stmt
+}
+
+// Replaces the expression with its value if it is constant, otherwise folds the parts of it that are
+func (c *checker) foldExpr(expr AST.Expr) AST.Expr {
+	if _, ok := expr.(*AST.Literal); ok {
+		return expr
+	}
+
+	if expr.IsComputable() {
+		val, err := AST.Eval(expr)
+		if err != nil {
+			c.report(D.Errorf(D.InvalidConstant, expr.GetPos(), expr.GetPos(), "Constant expression can't be evaluated: %v", err))
+			return expr
+		}
+		lit := &AST.Literal{Pos: expr.GetPos(), Kind: AST.Decimal, Text: val.String(), Value: val}
+		//A constant with a width keeps it where it is used
+		if id, ok := expr.(*AST.Ident); ok {
+			lit.Width = id.Obj.Decl.(*AST.ValueDecl).Width
+		}
+		//As does a bitwise not
+		if un, ok := expr.(*AST.UnaryExpr); ok && un.Op == AST.BitNot {
+			lit.Width = AST.SizedWidth(un.X)
+		}
+		return lit
+	}
+
+	switch obj := expr.(type) {
+	case *AST.ParenExpr:
+		obj.X = c.foldExpr(obj.X)
+	case *AST.UnaryExpr:
+		obj.X = c.foldExpr(obj.X)
+	case *AST.MathExpr:
+		obj.LHS = c.foldExpr(obj.LHS)
+		obj.RHS = c.foldExpr(obj.RHS)
+	case *AST.IndexExpr:
+		obj.Index = c.foldExpr(obj.Index)
+	case *AST.SliceExpr:
+		obj.Left = c.foldExpr(obj.Left)
+		obj.Right = c.foldExpr(obj.Right)
+	case *AST.ConcatExpr:
+		for i, part := range obj.Parts {
+			obj.Parts[i] = c.foldExpr(part)
+		}
+	case *AST.ReplicateExpr:
+		obj.Count = c.foldExpr(obj.Count)
+		obj.X = c.foldExpr(obj.X)
+	case *AST.CallExpr:
+		for i, arg := range obj.Args {
+			obj.Args[i] = c.foldExpr(arg)
+		}
+		return c.foldCall(obj)
+	}
+
+	return expr
+}
+
+// Every built in function is evaluated at compile time, so a call that can't be is an error
+func (c *checker) foldCall(call *AST.CallExpr) AST.Expr {
+	if call.IsComputable() {
+		return c.foldExpr(call)
+	}
+
+	if call.Fn != "width" {
+		c.report(D.Errorf(D.NotConstant, call.Pos, call.Pos, "The arguments of %s must be constants", call.Fn))
+		return call
+	}
+
+	id, ok := call.Args[0].(*AST.Ident)
+	if !ok || id.Obj == nil || id.Obj.Signal() == nil {
+		pos := call.Args[0].GetPos()
+		c.report(D.Errorf(D.NotConstant, pos, pos, "width takes a signal, not %s", call.Args[0]))
+		return call
+	}
+	if id.Obj.Signal().WidthExpr == nil {
+		c.report(D.Errorf(D.NotConstant, id.Pos, identEnd(id), "The width of %s isn't known until it is inferred", id.Name).
+			WithNote("give %s an explicit width", id.Name))
+	}
+	//Otherwise its width couldn't be evaluated, which has already been reported
+	return call
+}
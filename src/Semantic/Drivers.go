@@ -0,0 +1,263 @@
+package Semantic
+
+import (
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Registers are declared with a clock and assigned with <-, wires are assigned with =.
+// A register can only be driven on its own clock, so a sequence may only assign registers that share its clock
+
+// Checks that every assignment matches the kind of signal it drives
+func CheckDrivers(tree []AST.AST) []D.Diagnostic {
+	c := &checker{}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			c.checkModuleDrivers(mod)
+		}
+	}
+
+	return c.diagnostics
+}
+
+// Signals assigned and read within a module
+type driverInfo struct {
+	*checker
+	Driven map[*AST.Object]bool
+	Reads  []*AST.Ident
+}
+
+func (c *checker) checkModuleDrivers(mod AST.ModuleDecl) {
+	info := &driverInfo{checker: c, Driven: map[*AST.Object]bool{}}
+
+	for _, param := range mod.Params {
+		if param.Init != nil {
+			info.read(param.Init)
+		}
+	}
+	info.collect(&mod.Block, nil)
+
+	//Outputs are only known to be undriven once the whole module has been seen.
+	//Only the first read of each is reported
+	reported := map[*AST.Object]bool{}
+	for _, id := range info.Reads {
+		if id.Obj == nil || info.Driven[id.Obj] || reported[id.Obj] {
+			continue
+		}
+		if param, ok := id.Obj.Decl.(*AST.ParamDecl); ok && param.Dir == AST.Out {
+			reported[id.Obj] = true
+			pos := param.GetPos()
+			c.report(D.Errorf(D.UndrivenOutput, id.Pos, identEnd(id), "Output %s is read but never assigned", id.Name).
+				WithNote("%s is declared at %d:%d", id.Name, pos[0], pos[1]))
+		}
+	}
+}
+
+// Checks the assignments within a statement. seqClk is the clock of the sequence the statement is in, if any
+func (info *driverInfo) collect(stmt AST.Stmt, seqClk *AST.ClockDecl) {
+	switch obj := stmt.(type) {
+	case *AST.DeclStmt:
+		if decl, ok := obj.Decl.(*AST.SignalDecl); ok && decl.Init != nil {
+			info.read(decl.Init)
+		}
+		if inst, ok := obj.Decl.(*AST.InstanceDecl); ok {
+			info.connect(inst)
+		}
+
+	case *AST.AssignStmt:
+		info.checkDriver(obj, seqClk)
+
+	case *AST.IfStmt:
+		info.read(obj.Cond)
+		info.collect(obj.Body, seqClk)
+		if obj.Else != nil {
+			info.collect(obj.Else, seqClk)
+		}
+
+	case *AST.WaitStmt:
+		info.read(obj.X)
+
+	case *AST.LoopStmt:
+		if obj.Cond != nil {
+			info.read(obj.Cond)
+		}
+		info.collect(obj.Body, seqClk)
+
+	case *AST.SwitchStmt:
+		info.read(obj.X)
+		for _, arm := range obj.Cases {
+			info.collect(arm.Body, seqClk)
+		}
+
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			info.collect(inner, seqClk)
+		}
+
+	case *AST.SequenceStmt:
+		if obj.Start != nil {
+			info.read(obj.Start)
+		}
+		for _, status := range statusWires(obj) {
+			info.checkStatus(status)
+		}
+		info.collect(obj.Inner, &obj.Clk)
+		if obj.Abort != nil {
+			info.read(obj.Abort)
+		}
+		if obj.Cleanup != nil {
+			info.collect(obj.Cleanup, &obj.Clk)
+		}
+	}
+}
+
+// The busy and done wires of a sequence are driven by it like any other wire
+func (info *driverInfo) checkStatus(id *AST.Ident) {
+	if id.Obj == nil {
+		return
+	}
+	info.Driven[id.Obj] = true
+
+	decl := id.Obj.Signal()
+	if decl == nil {
+		return
+	}
+	pos := decl.GetPos()
+
+	if param, ok := id.Obj.Decl.(*AST.ParamDecl); ok && param.Dir == AST.In {
+		info.report(D.Errorf(D.InputAssignment, id.Pos, identEnd(id), "Cannot drive input %s from a sequence", id.Name).
+			WithNote("%s is declared as an input at %d:%d", id.Name, pos[0], pos[1]))
+	} else if decl.Clock != nil {
+		info.report(D.Errorf(D.ClockedWire, id.Pos, identEnd(id), "%s is a register, but the status of a sequence is a wire", id.Name).
+			WithNote("%s is declared with clock %s at %d:%d", id.Name, clockText(*decl.Clock), pos[0], pos[1]))
+	}
+}
+
+func (info *driverInfo) checkDriver(asmt *AST.AssignStmt, seqClk *AST.ClockDecl) {
+	info.readTarget(asmt.LHS)
+	info.read(asmt.RHS)
+
+	id := assignTarget(asmt.LHS)
+	if id == nil || id.Obj == nil {
+		return
+	}
+	info.Driven[id.Obj] = true
+
+	//Anything but a signal has already been reported
+	decl := id.Obj.Signal()
+	if decl == nil {
+		return
+	}
+	pos := decl.GetPos()
+
+	if param, ok := id.Obj.Decl.(*AST.ParamDecl); ok && param.Dir == AST.In {
+		info.report(D.Errorf(D.InputAssignment, id.Pos, identEnd(id), "Cannot assign to input %s", id.Name).
+			WithNote("%s is declared as an input at %d:%d", id.Name, pos[0], pos[1]))
+		return
+	}
+
+	switch {
+	case asmt.Op == AST.AsmtReg && decl.Clock == nil:
+		info.report(D.Errorf(D.UnclockedRegister, id.Pos, identEnd(id), "%s has no clock, so it can't be assigned with <-", id.Name).
+			WithNote("%s is declared at %d:%d; give it a clock with @ or assign it with =", id.Name, pos[0], pos[1]))
+
+	case asmt.Op == AST.Asmt && decl.Clock != nil:
+		info.report(D.Errorf(D.ClockedWire, id.Pos, identEnd(id), "%s is a register, so it must be assigned with <-", id.Name).
+			WithNote("%s is declared with clock %s at %d:%d", id.Name, clockText(*decl.Clock), pos[0], pos[1]))
+
+	case asmt.Op == AST.AsmtReg && seqClk != nil && !sameClock(*decl.Clock, *seqClk):
+		info.report(D.Errorf(D.CrossDomainDriver, id.Pos, identEnd(id), "%s is clocked by %s, but is assigned in a sequence clocked by %s",
+			id.Name, clockText(*decl.Clock), clockText(*seqClk)).
+			WithNote("%s is declared at %d:%d", id.Name, pos[0], pos[1]))
+	}
+}
+
+// Outputs of an instance drive what they are connected to, which is checked along with the instance
+func (info *driverInfo) connect(inst *AST.InstanceDecl) {
+	for _, conn := range inst.Conns {
+		if !isOutput(conn) {
+			info.read(conn.Value)
+			continue
+		}
+		info.readTarget(conn.Value)
+		if id := assignTarget(conn.Value); id != nil && id.Obj != nil {
+			info.Driven[id.Obj] = true
+		}
+	}
+}
+
+// Whether a connection is to an output of the instance
+func isOutput(conn AST.PortConn) bool {
+	if conn.Port.Obj == nil {
+		return false
+	}
+	port, ok := conn.Port.Obj.Decl.(*AST.ParamDecl)
+	return ok && port.Dir != AST.In
+}
+
+// Records every signal read by an expression
+func (info *driverInfo) read(expr AST.Expr) {
+	switch obj := expr.(type) {
+	case *AST.Ident:
+		info.Reads = append(info.Reads, obj)
+	case *AST.ParenExpr:
+		info.read(obj.X)
+	case *AST.UnaryExpr:
+		info.read(obj.X)
+	case *AST.MathExpr:
+		info.read(obj.LHS)
+		info.read(obj.RHS)
+	case *AST.IndexExpr:
+		info.read(obj.X)
+		info.read(obj.Index)
+	case *AST.SliceExpr:
+		info.read(obj.X)
+		info.read(obj.Left)
+		info.read(obj.Right)
+	case *AST.ConcatExpr:
+		for _, part := range obj.Parts {
+			info.read(part)
+		}
+	case *AST.ReplicateExpr:
+		info.read(obj.Count)
+		info.read(obj.X)
+	}
+}
+
+// The bit positions of an assignment target are read, the signal itself is not
+func (info *driverInfo) readTarget(lhs AST.Expr) {
+	switch obj := lhs.(type) {
+	case *AST.IndexExpr:
+		info.readTarget(obj.X)
+		info.read(obj.Index)
+	case *AST.SliceExpr:
+		info.readTarget(obj.X)
+		info.read(obj.Left)
+		info.read(obj.Right)
+	}
+}
+
+// The signal written to by an assignment
+func assignTarget(lhs AST.Expr) *AST.Ident {
+	switch obj := lhs.(type) {
+	case *AST.Ident:
+		return obj
+	case *AST.IndexExpr:
+		return assignTarget(obj.X)
+	case *AST.SliceExpr:
+		return assignTarget(obj.X)
+	}
+	return nil
+}
+
+func sameClock(a, b AST.ClockDecl) bool {
+	return a.Name.Obj == b.Name.Obj && a.Neg == b.Neg
+}
+
+func clockText(clk AST.ClockDecl) string {
+	if clk.Neg {
+		return "!" + clk.Name.Name
+	}
+	return clk.Name.Name
+}
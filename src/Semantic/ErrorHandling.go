@@ -0,0 +1,29 @@
+package Semantic
+
+import (
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// The state of a single pass over a file
+type checker struct {
+	// problems found while checking the file.
+	// Checking carries on after an error so that every problem is reported at once
+	diagnostics []D.Diagnostic
+
+	modules   map[string]*AST.ModuleDecl // every module of the file by name, set by Resolve
+	instances []*AST.InstanceDecl        // instances found while resolving, see Instance.go
+
+	templates       map[string]AST.ModuleDecl  // copies of the modules with parameters, see Specialize.go
+	specializations map[string]*AST.ModuleDecl // specializations by name
+	specialized     []AST.AST                  // specializations in the order they were made
+}
+
+func (c *checker) report(diag D.Diagnostic) {
+	c.diagnostics = append(c.diagnostics, diag)
+}
+
+// The position one past the end of an identifier
+func identEnd(id *AST.Ident) [2]int {
+	return [2]int{id.Pos[0], id.Pos[1] + len(id.Name)}
+}
@@ -0,0 +1,351 @@
+package Semantic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// A module is used within another by instantiating it, connecting each of its ports by name:
+//
+//	Adder Add(A: X, B: Y, Sum: Total)
+//
+// Parameters are connected the same way, and are optional. Every port must be connected.
+// Inputs can be connected to any value of the same width, outputs only to a signal of the same width,
+// and a clocked port must be connected to a signal on the clock its own clock is connected to
+
+// Instances found while resolving are linked to their ports once every module is resolved
+
+func (c *checker) resolveInstance(scope *AST.Scope, inst *AST.InstanceDecl) {
+	for _, conn := range inst.Conns {
+		c.resolveExpr(scope, conn.Value)
+	}
+
+	inst.Def = c.modules[inst.Module.Name]
+	if inst.Def != nil {
+		c.instances = append(c.instances, inst)
+	} else {
+		var names []string
+		for name := range c.modules {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		diag := D.Errorf(D.UnknownModule, inst.Module.Pos, identEnd(&inst.Module), "Unknown module %s", inst.Module.Name)
+		if suggestion := closestName(inst.Module.Name, names); suggestion != "" {
+			diag = diag.WithNote("did you mean %s?", suggestion)
+		}
+		c.report(diag)
+	}
+
+	c.declare(scope, &inst.Name, inst, AST.Instance)
+}
+
+// The port or parameter of a module with the given name
+func portObject(mod *AST.ModuleDecl, name string) *AST.Object {
+	for _, value := range mod.Consts {
+		if value.Name.Name == name {
+			return value.Name.Obj
+		}
+	}
+	for _, param := range mod.Params {
+		if param.Name.Name == name {
+			return param.Name.Obj
+		}
+	}
+	return nil
+}
+
+// Links each connection to the port it names, reporting ports that don't exist or aren't connected
+func (c *checker) linkInstance(inst *AST.InstanceDecl) {
+	mod := inst.Def
+	connected := map[string]*AST.PortConn{}
+
+	for i := range inst.Conns {
+		conn := &inst.Conns[i]
+		port := &conn.Port
+
+		port.Obj = portObject(mod, port.Name)
+		if port.Obj == nil {
+			var names []string
+			for _, value := range mod.Consts {
+				names = append(names, value.Name.Name)
+			}
+			for _, param := range mod.Params {
+				names = append(names, param.Name.Name)
+			}
+
+			diag := D.Errorf(D.UnknownPort, port.Pos, identEnd(port), "%s has no port named %s", mod.Name.Name, port.Name)
+			if suggestion := closestName(port.Name, names); suggestion != "" {
+				diag = diag.WithNote("did you mean %s?", suggestion)
+			}
+			c.report(diag)
+			continue
+		}
+
+		if prev := connected[port.Name]; prev != nil {
+			pos := prev.Port.Pos
+			c.report(D.Errorf(D.DuplicateName, port.Pos, identEnd(port), "Port %s of %s is connected more than once", port.Name, inst.Name.Name).
+				WithNote("%s is also connected at %d:%d", port.Name, pos[0], pos[1]))
+			continue
+		}
+		connected[port.Name] = conn
+	}
+
+	//Parameters have a default, ports don't
+	var missing []string
+	for _, param := range mod.Params {
+		if connected[param.Name.Name] == nil {
+			missing = append(missing, param.Name.Name)
+		}
+	}
+	if len(missing) > 0 {
+		what := "port " + missing[0]
+		if len(missing) > 1 {
+			what = "ports " + strings.Join(missing, ", ")
+		}
+		pos := mod.GetPos()
+		c.report(D.Errorf(D.UnconnectedPort, inst.Name.Pos, identEnd(&inst.Name), "%s doesn't connect %s of %s", inst.Name.Name, what, mod.Name.Name).
+			WithNote("%s is declared at %d:%d", mod.Name.Name, pos[0], pos[1]))
+	}
+}
+
+// The instances declared within a module
+func moduleInstances(mod AST.ModuleDecl) []*AST.InstanceDecl {
+	var list []*AST.InstanceDecl
+	eachStmt(&mod.Block, func(stmt AST.Stmt) {
+		if decl, ok := stmt.(*AST.DeclStmt); ok {
+			if inst, ok := decl.Decl.(*AST.InstanceDecl); ok && inst.Def != nil {
+				list = append(list, inst)
+			}
+		}
+	})
+	return list
+}
+
+// A module can't contain itself, either directly or through the modules it instantiates
+func (c *checker) checkRecursion(tree []AST.AST) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var path []*AST.InstanceDecl
+
+	var visit func(mod AST.ModuleDecl)
+	visit = func(mod AST.ModuleDecl) {
+		state[mod.Name.Name] = visiting
+		for _, inst := range moduleInstances(mod) {
+			name := inst.Def.Name.Name
+			switch state[name] {
+			case unvisited:
+				path = append(path, inst)
+				visit(*inst.Def)
+				path = path[:len(path)-1]
+			case visiting:
+				//The instances from where the loop starts back round to it
+				names := []string{name}
+				start := len(path)
+				for i := len(path) - 1; i >= 0 && path[i].Def.Name.Name != name; i-- {
+					start = i
+				}
+				for _, step := range append(path[start:], inst) {
+					names = append(names, step.Def.Name.Name)
+				}
+				c.report(D.Errorf(D.RecursiveInstance, inst.Module.Pos, identEnd(&inst.Module),
+					"%s contains itself: %s", name, strings.Join(names, " -> ")))
+			}
+		}
+		state[mod.Name.Name] = done
+	}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok && state[mod.Name.Name] == unvisited {
+			visit(mod)
+		}
+	}
+}
+
+// Checks that every instance is connected to values of the right width, direction and clock
+func CheckInstances(tree []AST.AST) []D.Diagnostic {
+	c := &checker{}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			c.checkInstancePlacement(&mod.Block, "")
+			for _, inst := range moduleInstances(mod) {
+				c.checkInstance(inst)
+			}
+		}
+	}
+
+	return c.diagnostics
+}
+
+// Instances always exist, so they can't be inside an if or a sequence.
+// within describes the statement the current one is in, if any
+func (c *checker) checkInstancePlacement(stmt AST.Stmt, within string) {
+	switch obj := stmt.(type) {
+	case *AST.DeclStmt:
+		if inst, ok := obj.Decl.(*AST.InstanceDecl); ok && within != "" {
+			c.report(D.Errorf(D.NestedInstance, inst.Name.Pos, identEnd(&inst.Name), "%s can't be instantiated inside %s", inst.Name.Name, within).
+				WithNote("instances always exist, so they must be in the main body of the module"))
+		}
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			c.checkInstancePlacement(inner, within)
+		}
+	case *AST.IfStmt:
+		what := fmt.Sprintf("the if at %d:%d", obj.Pos[0], obj.Pos[1])
+		c.checkInstancePlacement(obj.Body, what)
+		if obj.Else != nil {
+			c.checkInstancePlacement(obj.Else, what)
+		}
+	case *AST.SwitchStmt:
+		what := fmt.Sprintf("the switch at %d:%d", obj.Pos[0], obj.Pos[1])
+		for _, arm := range obj.Cases {
+			c.checkInstancePlacement(arm.Body, what)
+		}
+	case *AST.LoopStmt:
+		c.checkInstancePlacement(obj.Body, fmt.Sprintf("the loop at %d:%d", obj.Pos[0], obj.Pos[1]))
+	case *AST.SequenceStmt:
+		c.checkInstancePlacement(obj.Inner, fmt.Sprintf("the sequence at %d:%d", obj.StartPos[0], obj.StartPos[1]))
+		if obj.Cleanup != nil {
+			c.checkInstancePlacement(obj.Cleanup, fmt.Sprintf("the sequence at %d:%d", obj.StartPos[0], obj.StartPos[1]))
+		}
+	}
+}
+
+func (c *checker) checkInstance(inst *AST.InstanceDecl) {
+	for _, conn := range inst.Conns {
+		if conn.Port.Obj == nil {
+			continue
+		}
+		param, ok := conn.Port.Obj.Decl.(*AST.ParamDecl)
+		if !ok {
+			//Parameters have already been folded into the module
+			continue
+		}
+
+		name := fmt.Sprintf("port %s of %s", param.Name.Name, inst.Name.Name)
+		if param.Dir == AST.In {
+			c.checkConnWidth(conn.Value, signalWidth(&param.SignalDecl), name, true)
+			c.checkInputClock(inst, conn, param)
+		} else if c.checkOutputTarget(conn, param, name) {
+			c.checkConnWidth(conn.Value, signalWidth(&param.SignalDecl), name, false)
+			c.checkOutputClock(inst, conn, param)
+		}
+	}
+}
+
+// Ports must be connected to a value of their own width.
+// Inputs can also be given an unsized literal that fits, or drop the carry bit of an addition
+func (c *checker) checkConnWidth(value AST.Expr, width int, name string, input bool) {
+	pos := value.GetPos()
+
+	if lit, ok := stripParens(value).(*AST.Literal); ok && lit.Width == 0 && input {
+		if lit.Value.BitLen() > width {
+			c.report(D.Errorf(D.ValueOverflow, pos, pos, "%s does not fit in %s, which is %d bits wide", lit.Text, name, width))
+		}
+		return
+	}
+
+	got := widthOf(value)
+	if got == width || (input && assignedWidth(value) == width) {
+		return
+	}
+	c.report(D.Errorf(D.PortWidthMismatch, pos, pos, "Connecting %d bits to %s, which is %d bits wide", got, name, width))
+}
+
+// The signal a clock port of an instance is connected to
+func mappedClock(inst *AST.InstanceDecl, clk *AST.ClockDecl) *AST.Ident {
+	for _, conn := range inst.Conns {
+		if conn.Port.Obj == clk.Name.Obj {
+			id, _ := conn.Value.(*AST.Ident)
+			return id
+		}
+	}
+	return nil
+}
+
+// The clock of the signal a port is connected to, or nil if it isn't a register
+func connClock(value AST.Expr) *AST.ClockDecl {
+	id := assignTarget(value)
+	if id == nil || id.Obj == nil || id.Obj.Signal() == nil {
+		return nil
+	}
+	return id.Obj.Signal().Clock
+}
+
+// A clocked input connected directly to a register must be connected to one of the same clock
+func (c *checker) checkInputClock(inst *AST.InstanceDecl, conn AST.PortConn, param *AST.ParamDecl) {
+	outer := connClock(conn.Value)
+	if param.Clock == nil || outer == nil {
+		return
+	}
+	c.checkClockMatch(inst, conn, param, outer)
+}
+
+func (c *checker) checkOutputClock(inst *AST.InstanceDecl, conn AST.PortConn, param *AST.ParamDecl) {
+	outer := connClock(conn.Value)
+	if outer == nil {
+		return
+	}
+
+	id := assignTarget(conn.Value)
+	if param.Clock == nil {
+		c.report(D.Errorf(D.PortClockMismatch, id.Pos, identEnd(id), "%s is a register, but output %s of %s isn't clocked",
+			id.Name, param.Name.Name, inst.Name.Name).
+			WithNote("declare %s without a clock, as it is driven by %s", id.Name, inst.Name.Name))
+		return
+	}
+	c.checkClockMatch(inst, conn, param, outer)
+}
+
+func (c *checker) checkClockMatch(inst *AST.InstanceDecl, conn AST.PortConn, param *AST.ParamDecl, outer *AST.ClockDecl) {
+	mapped := mappedClock(inst, param.Clock)
+	if mapped == nil {
+		pos := conn.Value.GetPos()
+		c.report(D.Errorf(D.PortClockMismatch, pos, pos, "%s of %s is clocked by %s, which must be connected to a signal",
+			param.Name.Name, inst.Name.Name, param.Clock.Name.Name))
+		return
+	}
+
+	expected := AST.ClockDecl{Name: *mapped, Neg: param.Clock.Neg}
+	if !sameClock(expected, *outer) {
+		id := assignTarget(conn.Value)
+		c.report(D.Errorf(D.PortClockMismatch, id.Pos, identEnd(id), "%s is clocked by %s, but %s of %s is clocked by %s",
+			id.Name, clockText(*outer), param.Name.Name, inst.Name.Name, clockText(expected)).
+			WithNote("%s of %s is connected to %s", param.Clock.Name.Name, inst.Name.Name, mapped.Name))
+	}
+}
+
+// Outputs drive the signal they are connected to, so it must be a signal that can be driven.
+// Returns false if it isn't
+func (c *checker) checkOutputTarget(conn AST.PortConn, param *AST.ParamDecl, name string) bool {
+	id := assignTarget(conn.Value)
+	if id == nil {
+		pos := conn.Value.GetPos()
+		c.report(D.Errorf(D.PortDirection, pos, pos, "Output %s must be connected to a signal", name))
+		return false
+	}
+	if id.Obj == nil || id.Obj.Signal() == nil {
+		return false
+	}
+
+	pos := id.Obj.GetPos()
+	if outer, ok := id.Obj.Decl.(*AST.ParamDecl); ok && outer.Dir == AST.In {
+		c.report(D.Errorf(D.InputAssignment, id.Pos, identEnd(id), "Cannot connect output %s to input %s", name, id.Name).
+			WithNote("%s is declared as an input at %d:%d", id.Name, pos[0], pos[1]))
+		return false
+	}
+	if id.Obj.Signal().Init != nil {
+		c.report(D.Errorf(D.PortDirection, id.Pos, identEnd(id), "%s is driven by output %s, so it can't have an initial value", id.Name, name).
+			WithNote("%s is declared at %d:%d", id.Name, pos[0], pos[1]))
+	}
+	return true
+}
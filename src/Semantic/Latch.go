@@ -0,0 +1,183 @@
+package Semantic
+
+import (
+	"fmt"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// A combinational signal that isn't assigned on every path through the module has to hold its value,
+// which makes it a latch. Latches are only allowed when a signal is declared with the latch keyword.
+// Assigning part of a signal counts as assigning the whole signal
+
+// Reports combinational signals that would become latches
+func CheckLatches(tree []AST.AST) []D.Diagnostic {
+	c := &checker{}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			c.checkModuleLatches(mod)
+		}
+	}
+
+	return c.diagnostics
+}
+
+func (c *checker) checkModuleLatches(mod AST.ModuleDecl) {
+	assigned := definitelyAssigned(&mod.Block)
+
+	//The first combinational assignment to each signal, in source order
+	var firsts []*AST.Ident
+	seen := map[*AST.Object]bool{}
+	eachStmt(&mod.Block, func(stmt AST.Stmt) {
+		if asmt, ok := stmt.(*AST.AssignStmt); ok && asmt.Op == AST.Asmt {
+			if id := assignTarget(asmt.LHS); id != nil && id.Obj != nil && !seen[id.Obj] {
+				seen[id.Obj] = true
+				firsts = append(firsts, id)
+			}
+		}
+	})
+
+	for _, id := range firsts {
+		decl := id.Obj.Signal()
+		pos := decl.GetPos()
+
+		if assigned[id.Obj] {
+			if decl.Latch {
+				c.report(D.Warningf(D.UnneededLatch, decl.Name.Pos, identEnd(&decl.Name),
+					"%s is declared as a latch, but is assigned on every path", decl.Name.Name))
+			}
+			continue
+		}
+		if decl.Latch {
+			continue
+		}
+
+		path, _ := missingPath(&mod.Block, id.Obj)
+		diag := D.Errorf(D.LatchInferred, id.Pos, identEnd(id), "%s is not assigned on every path, so it would be a latch", id.Name)
+		if len(path) > 0 {
+			diag = diag.WithNote("%s keeps its value when %s", id.Name, strings.Join(path, " and "))
+		}
+		c.report(diag.WithNote("assign %s on every path, or declare it at %d:%d with latch if one is intended", id.Name, pos[0], pos[1]))
+	}
+}
+
+// The signals assigned with = on every path through the statement
+func definitelyAssigned(stmt AST.Stmt) map[*AST.Object]bool {
+	assigned := map[*AST.Object]bool{}
+
+	switch obj := stmt.(type) {
+	case *AST.AssignStmt:
+		if id := assignTarget(obj.LHS); obj.Op == AST.Asmt && id != nil && id.Obj != nil {
+			assigned[id.Obj] = true
+		}
+
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			for sig := range definitelyAssigned(inner) {
+				assigned[sig] = true
+			}
+		}
+
+	case *AST.IfStmt:
+		if obj.Else == nil {
+			break
+		}
+		other := definitelyAssigned(obj.Else)
+		for sig := range definitelyAssigned(obj.Body) {
+			if other[sig] {
+				assigned[sig] = true
+			}
+		}
+
+	case *AST.SwitchStmt:
+		//A value without an arm leaves every signal unassigned
+		if !obj.Complete || len(obj.Cases) == 0 {
+			break
+		}
+		assigned = definitelyAssigned(obj.Cases[0].Body)
+		for _, arm := range obj.Cases[1:] {
+			other := definitelyAssigned(arm.Body)
+			for sig := range assigned {
+				if !other[sig] {
+					delete(assigned, sig)
+				}
+			}
+		}
+	}
+
+	return assigned
+}
+
+// Describes a path through the statement that doesn't assign the signal.
+// Returns false if every path assigns it
+func missingPath(stmt AST.Stmt, sig *AST.Object) ([]string, bool) {
+	switch obj := stmt.(type) {
+	case *AST.AssignStmt:
+		id := assignTarget(obj.LHS)
+		return nil, obj.Op != AST.Asmt || id == nil || id.Obj != sig
+
+	case *AST.BlockStmt:
+		//Every statement of the block is on the path
+		var path []string
+		for _, inner := range obj.StmtList {
+			innerPath, missing := missingPath(inner, sig)
+			if !missing {
+				return nil, false
+			}
+			path = append(path, innerPath...)
+		}
+		return path, true
+
+	case *AST.IfStmt:
+		//Conditions that don't decide whether the signal is assigned are left out of the path
+		if !assigns(obj, sig) {
+			return nil, true
+		}
+
+		cond := fmt.Sprintf("the condition at %d:%d is ", obj.Cond.GetPos()[0], obj.Cond.GetPos()[1])
+		if path, missing := missingPath(obj.Body, sig); missing {
+			return append([]string{cond + "true"}, path...), true
+		}
+		if obj.Else == nil {
+			return []string{cond + "false"}, true
+		}
+		if path, missing := missingPath(obj.Else, sig); missing {
+			return append([]string{cond + "false"}, path...), true
+		}
+		return nil, false
+
+	case *AST.SwitchStmt:
+		if !assigns(obj, sig) {
+			return nil, true
+		}
+
+		if !obj.Complete {
+			return []string{fmt.Sprintf("no arm of the switch at %d:%d matches", obj.Pos[0], obj.Pos[1])}, true
+		}
+		for _, arm := range obj.Cases {
+			if path, missing := missingPath(arm.Body, sig); missing {
+				what := fmt.Sprintf("the arm at %d:%d is taken", arm.Pos[0], arm.Pos[1])
+				return append([]string{what}, path...), true
+			}
+		}
+		return nil, false
+	}
+
+	return nil, true
+}
+
+// Whether the signal is assigned with = anywhere within the statement
+func assigns(stmt AST.Stmt, sig *AST.Object) bool {
+	found := false
+	eachStmt(stmt, func(inner AST.Stmt) {
+		if asmt, ok := inner.(*AST.AssignStmt); ok && asmt.Op == AST.Asmt {
+			if id := assignTarget(asmt.LHS); id != nil && id.Obj == sig {
+				found = true
+			}
+		}
+	})
+	return found
+}
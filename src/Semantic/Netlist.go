@@ -0,0 +1,353 @@
+package Semantic
+
+import (
+	"fmt"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Statements in a module run in parallel, so every signal must have a single driver.
+// The combinational logic, the register logic and each sequence are separate drivers,
+// and a signal can only be assigned by one of them. Within the combinational or register logic,
+// a signal can be assigned unconditionally once, with conditional assignments taking priority in source order.
+//
+// Combinational assignments take no time, so a signal that depends on itself through them is a loop.
+// The outputs of an instance drive the signals they are connected to, but paths through instances aren't followed
+
+// A single assignment, with everything its value depends on
+type driver struct {
+	Asmt        *AST.AssignStmt
+	Target      *AST.Ident
+	Bits        bitRange
+	Seq         *AST.SequenceStmt // The outermost sequence it is in, nil outside of sequences
+	Inst        *AST.InstanceDecl // The instance whose output it is, if it isn't an assignment
+	Conditional bool
+	Reads       []signalRead // Including the conditions it is under
+	Conds       []signalRead // Read by the conditions it is under
+}
+
+type signalRead struct {
+	Ident *AST.Ident
+	Bits  bitRange
+}
+
+// The bits of a signal used by an assignment or read, when they are known
+type bitRange struct {
+	Low, High int
+	Known     bool
+}
+
+func (r bitRange) overlaps(other bitRange) bool {
+	if !r.Known || !other.Known {
+		return true
+	}
+	return r.Low <= other.High && other.Low <= r.High
+}
+
+// Reports signals with more than one driver and loops in the combinational logic
+func CheckNetlist(tree []AST.AST) []D.Diagnostic {
+	c := &checker{}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			var drivers []*driver
+			collectDrivers(&mod.Block, nil, nil, false, &drivers)
+			c.checkMultipleDrivers(drivers)
+			c.checkLoops(drivers)
+		}
+	}
+
+	return c.diagnostics
+}
+
+func collectDrivers(stmt AST.Stmt, seq *AST.SequenceStmt, conds []signalRead, conditional bool, drivers *[]*driver) {
+	switch obj := stmt.(type) {
+	case *AST.AssignStmt:
+		target := assignTarget(obj.LHS)
+		if target == nil || target.Obj == nil {
+			return
+		}
+		drv := &driver{Asmt: obj, Target: target, Bits: selectedBits(obj.LHS), Seq: seq, Conditional: conditional, Conds: conds}
+		drv.Reads = append(drv.Reads, conds...)
+		drv.Reads = append(drv.Reads, targetReads(obj.LHS)...)
+		drv.Reads = append(drv.Reads, reads(obj.RHS)...)
+		*drivers = append(*drivers, drv)
+
+	case *AST.DeclStmt:
+		if inst, ok := obj.Decl.(*AST.InstanceDecl); ok {
+			collectOutputs(inst, drivers)
+		}
+
+	case *AST.IfStmt:
+		inner := append(append([]signalRead{}, conds...), reads(obj.Cond)...)
+		collectDrivers(obj.Body, seq, inner, true, drivers)
+		if obj.Else != nil {
+			collectDrivers(obj.Else, seq, inner, true, drivers)
+		}
+
+	case *AST.SwitchStmt:
+		inner := append(append([]signalRead{}, conds...), reads(obj.X)...)
+		for _, arm := range obj.Cases {
+			collectDrivers(arm.Body, seq, inner, true, drivers)
+		}
+
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			collectDrivers(inner, seq, conds, conditional, drivers)
+		}
+
+	case *AST.SequenceStmt:
+		//Nested sequences are part of the same driver as the sequence around them.
+		//Every step of a sequence only applies while the sequence is in it
+		if seq == nil {
+			seq = obj
+		}
+		for _, status := range statusWires(obj) {
+			if status.Obj != nil {
+				asmt := &AST.AssignStmt{Pos: status.Pos, Op: AST.Asmt, LHS: status}
+				*drivers = append(*drivers, &driver{Asmt: asmt, Target: status, Seq: seq})
+			}
+		}
+		//Nothing in the sequence happens until it is started, and an abort can stop any of it
+		if obj.Start != nil {
+			conds = append(append([]signalRead{}, conds...), reads(obj.Start)...)
+		}
+		if obj.Abort != nil {
+			conds = append(append([]signalRead{}, conds...), reads(obj.Abort)...)
+		}
+		collectSteps(obj.Inner, seq, conds, drivers)
+		if obj.Cleanup != nil {
+			collectSteps(obj.Cleanup, seq, conds, drivers)
+		}
+
+	case *AST.LoopStmt:
+		//Every step of a while loop depends on its condition
+		if obj.Cond != nil {
+			conds = append(append([]signalRead{}, conds...), reads(obj.Cond)...)
+		}
+		collectSteps(obj.Body, seq, conds, drivers)
+	}
+}
+
+// The steps after a wait only happen once its condition is true
+func collectSteps(stmt AST.Stmt, seq *AST.SequenceStmt, conds []signalRead, drivers *[]*driver) {
+	for _, step := range steps(stmt) {
+		collectDrivers(step, seq, conds, true, drivers)
+		if wait, ok := step.(*AST.WaitStmt); ok && !wait.Cycles {
+			conds = append(append([]signalRead{}, conds...), reads(wait.X)...)
+		}
+	}
+}
+
+// Each output of an instance drives the signal it is connected to, as a register if the output is clocked
+func collectOutputs(inst *AST.InstanceDecl, drivers *[]*driver) {
+	for _, conn := range inst.Conns {
+		target := assignTarget(conn.Value)
+		if !isOutput(conn) || target == nil || target.Obj == nil {
+			continue
+		}
+
+		op := AST.Asmt
+		if conn.Port.Obj.Signal().Clock != nil {
+			op = AST.AsmtReg
+		}
+		asmt := &AST.AssignStmt{Pos: conn.Value.GetPos(), Op: op, LHS: conn.Value}
+		*drivers = append(*drivers, &driver{Asmt: asmt, Target: target, Bits: selectedBits(conn.Value), Inst: inst, Reads: targetReads(conn.Value)})
+	}
+}
+
+// The signals read by an expression
+func reads(expr AST.Expr) []signalRead {
+	var list []signalRead
+
+	switch obj := expr.(type) {
+	case *AST.Ident:
+		if obj.Obj != nil {
+			list = append(list, signalRead{Ident: obj})
+		}
+	case *AST.IndexExpr, *AST.SliceExpr:
+		list = append(list, targetReads(obj)...)
+		if id := assignTarget(obj); id != nil && id.Obj != nil {
+			list = append(list, signalRead{Ident: id, Bits: selectedBits(obj)})
+		}
+	case *AST.ParenExpr:
+		list = reads(obj.X)
+	case *AST.UnaryExpr:
+		list = reads(obj.X)
+	case *AST.MathExpr:
+		list = append(reads(obj.LHS), reads(obj.RHS)...)
+	case *AST.ConcatExpr:
+		for _, part := range obj.Parts {
+			list = append(list, reads(part)...)
+		}
+	case *AST.ReplicateExpr:
+		list = append(reads(obj.Count), reads(obj.X)...)
+	}
+
+	return list
+}
+
+// The signals read to find the bits selected by an index or slice
+func targetReads(expr AST.Expr) []signalRead {
+	switch obj := expr.(type) {
+	case *AST.IndexExpr:
+		return append(targetReads(obj.X), reads(obj.Index)...)
+	case *AST.SliceExpr:
+		return append(targetReads(obj.X), append(reads(obj.Left), reads(obj.Right)...)...)
+	}
+	return nil
+}
+
+func selectedBits(expr AST.Expr) bitRange {
+	switch obj := expr.(type) {
+	case *AST.IndexExpr:
+		if idx, ok := literalValue(obj.Index); ok {
+			return bitRange{Low: int(idx), High: int(idx), Known: true}
+		}
+
+	case *AST.SliceExpr:
+		left, leftOk := literalValue(obj.Left)
+		right, rightOk := literalValue(obj.Right)
+		if !leftOk || !rightOk {
+			break
+		}
+		switch obj.Kind {
+		case AST.SliceRange:
+			return bitRange{Low: int(right), High: int(left), Known: true}
+		case AST.SliceUp:
+			return bitRange{Low: int(left), High: int(left + right - 1), Known: true}
+		case AST.SliceDown:
+			return bitRange{Low: int(left - right + 1), High: int(left), Known: true}
+		}
+	}
+
+	//The whole signal
+	return bitRange{}
+}
+
+// Describes where a signal is driven from
+func (drv *driver) source() string {
+	switch {
+	case drv.Inst != nil:
+		return "the instance " + drv.Inst.Name.Name
+	case drv.Seq != nil:
+		return fmt.Sprintf("the sequence at %d:%d", drv.Seq.StartPos[0], drv.Seq.StartPos[1])
+	case drv.Asmt.Op == AST.AsmtReg:
+		return "the register logic"
+	}
+	return "the combinational logic"
+}
+
+func (c *checker) checkMultipleDrivers(drivers []*driver) {
+	reported := map[*AST.Object]bool{}
+
+	for i, drv := range drivers {
+		for _, prev := range drivers[:i] {
+			if prev.Target.Obj != drv.Target.Obj || reported[drv.Target.Obj] || !prev.Bits.overlaps(drv.Bits) {
+				continue
+			}
+
+			name := drv.Target.Name
+			pos := prev.Asmt.Pos
+			if prev.source() != drv.source() {
+				reported[drv.Target.Obj] = true
+				c.report(D.Errorf(D.MultipleDrivers, drv.Target.Pos, identEnd(drv.Target),
+					"%s is driven by both %s and %s", name, prev.source(), drv.source()).
+					WithNote("%s is also assigned at %d:%d", name, pos[0], pos[1]))
+			} else if drv.Seq == nil && !prev.Conditional && !drv.Conditional {
+				reported[drv.Target.Obj] = true
+				c.report(D.Errorf(D.MultipleDrivers, drv.Target.Pos, identEnd(drv.Target),
+					"%s is assigned unconditionally more than once", name).
+					WithNote("%s is also assigned at %d:%d", name, pos[0], pos[1]))
+			}
+		}
+	}
+}
+
+// A combinational dependency of one signal on another
+type edge struct {
+	From *AST.Object
+	Drv  *driver
+}
+
+func (c *checker) checkLoops(drivers []*driver) {
+	//Signals in the order they are first assigned, so loops are reported consistently
+	var nodes []*AST.Object
+	deps := map[*AST.Object][]edge{}
+	for _, drv := range drivers {
+		if drv.Asmt.Op != AST.Asmt || drv.Seq != nil {
+			continue
+		}
+		to := drv.Target.Obj
+		if _, ok := deps[to]; !ok {
+			nodes = append(nodes, to)
+			deps[to] = nil
+		}
+		for _, read := range drv.Reads {
+			//Assigning some bits of a signal from other bits of itself isn't a loop
+			if read.Ident.Obj == to && !read.Bits.overlaps(drv.Bits) {
+				continue
+			}
+			deps[to] = append(deps[to], edge{From: read.Ident.Obj, Drv: drv})
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[*AST.Object]int{}
+	var stack []edge
+
+	var visit func(node *AST.Object)
+	visit = func(node *AST.Object) {
+		state[node] = visiting
+		for _, dep := range deps[node] {
+			switch state[dep.From] {
+			case unvisited:
+				stack = append(stack, dep)
+				visit(dep.From)
+				stack = stack[:len(stack)-1]
+			case visiting:
+				//The edges from where the loop starts back round to it
+				start := len(stack)
+				for i := range stack {
+					if stack[i].Drv.Target.Obj == dep.From {
+						start = i
+						break
+					}
+				}
+				c.reportLoop(append(append([]edge{}, stack[start:]...), dep))
+			}
+		}
+		state[node] = done
+	}
+
+	for _, node := range nodes {
+		if state[node] == unvisited {
+			visit(node)
+		}
+	}
+}
+
+// Reports a loop, given the edges that go around it.
+// Each edge is a signal being assigned from the signal of the next edge
+func (c *checker) reportLoop(loop []edge) {
+	//The last edge closes the loop, so it starts and ends with the same signal
+	names := []string{loop[len(loop)-1].From.Name}
+	for i := len(loop) - 1; i >= 0; i-- {
+		names = append(names, loop[i].Drv.Target.Name)
+	}
+
+	first := loop[len(loop)-1].Drv
+	diag := D.Errorf(D.CombinationalLoop, first.Target.Pos, identEnd(first.Target),
+		"Combinational loop: %s", strings.Join(names, " -> "))
+	for i := len(loop) - 1; i >= 0; i-- {
+		pos := loop[i].Drv.Asmt.Pos
+		diag = diag.WithNote("%s is assigned from %s at %d:%d", loop[i].Drv.Target.Name, loop[i].From.Name, pos[0], pos[1])
+	}
+	c.report(diag)
+}
@@ -0,0 +1,289 @@
+package Semantic
+
+import (
+	"fmt"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Links every identifier in the tree to the object it refers to.
+// Names must be declared before they are used, except for ports which are visible to the whole module.
+// Constants declared outside of a module are visible to every module after them
+func Resolve(tree []AST.AST) []D.Diagnostic {
+	c := &checker{}
+
+	//Modules can be used before they are declared
+	c.modules = map[string]*AST.ModuleDecl{}
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			c.declareModule(mod)
+		}
+	}
+
+	file := AST.NewScope(nil)
+	for _, elem := range tree {
+		switch obj := elem.(type) {
+		case *AST.ValueDecl:
+			c.resolveValue(file, obj)
+		case AST.ModuleDecl:
+			c.resolveModule(file, obj)
+		}
+	}
+
+	//Ports are only linked once every module has been resolved
+	for _, inst := range c.instances {
+		c.linkInstance(inst)
+	}
+	c.checkRecursion(tree)
+
+	return c.diagnostics
+}
+
+func (c *checker) declareModule(mod AST.ModuleDecl) {
+	if prev := c.modules[mod.Name.Name]; prev != nil {
+		pos := prev.GetPos()
+		c.report(D.Errorf(D.DuplicateName, mod.Name.Pos, identEnd(&mod.Name), "Module %s is already declared", mod.Name.Name).
+			WithNote("previous declaration of %s at %d:%d", mod.Name.Name, pos[0], pos[1]))
+		return
+	}
+	c.modules[mod.Name.Name] = &mod
+}
+
+func (c *checker) resolveModule(file *AST.Scope, mod AST.ModuleDecl) {
+	scope := AST.NewScope(file)
+
+	//Parameters come first, so the widths of ports can depend on them
+	for i := range mod.Consts {
+		c.resolveValue(scope, &mod.Consts[i])
+	}
+	for i := range mod.Params {
+		param := &mod.Params[i]
+		c.declare(scope, &param.Name, param, AST.Port)
+	}
+	//Ports can be clocked by a port listed after them
+	for i := range mod.Params {
+		c.resolveSignal(scope, &mod.Params[i].SignalDecl)
+	}
+
+	c.resolveBlock(scope, &mod.Block)
+}
+
+func (c *checker) resolveBlock(outer *AST.Scope, blk *AST.BlockStmt) {
+	scope := AST.NewScope(outer)
+	for _, stmt := range blk.StmtList {
+		c.resolveStmt(scope, stmt)
+	}
+}
+
+func (c *checker) resolveStmt(scope *AST.Scope, stmt AST.Stmt) {
+	switch obj := stmt.(type) {
+	case *AST.DeclStmt:
+		if decl, ok := obj.Decl.(*AST.SignalDecl); ok {
+			//The signal isn't visible from its own declaration
+			c.resolveSignal(scope, decl)
+			c.declare(scope, &decl.Name, decl, AST.Signal)
+		}
+		if decl, ok := obj.Decl.(*AST.ValueDecl); ok {
+			c.resolveValue(scope, decl)
+		}
+		if decl, ok := obj.Decl.(*AST.InstanceDecl); ok {
+			c.resolveInstance(scope, decl)
+		}
+
+	case *AST.AssignStmt:
+		c.resolveExpr(scope, obj.LHS)
+		c.resolveExpr(scope, obj.RHS)
+
+	case *AST.IfStmt:
+		c.resolveExpr(scope, obj.Cond)
+		c.resolveStmt(scope, obj.Body)
+		if obj.Else != nil {
+			c.resolveStmt(scope, obj.Else)
+		}
+
+	case *AST.WaitStmt:
+		c.resolveExpr(scope, obj.X)
+
+	case *AST.LoopStmt:
+		if obj.Cond != nil {
+			c.resolveExpr(scope, obj.Cond)
+		} else {
+			c.resolveExpr(scope, obj.Count)
+		}
+		c.resolveStmt(scope, obj.Body)
+
+	case *AST.SwitchStmt:
+		c.resolveExpr(scope, obj.X)
+		for _, arm := range obj.Cases {
+			for _, value := range arm.Values {
+				c.resolveExpr(scope, value)
+			}
+			c.resolveStmt(scope, arm.Body)
+		}
+
+	case *AST.SequenceStmt:
+		c.resolveClock(scope, &obj.Clk.Name)
+		if obj.Start != nil {
+			c.resolveExpr(scope, obj.Start)
+		}
+		for _, status := range statusWires(obj) {
+			c.resolveStatus(scope, status)
+		}
+		c.resolveStmt(scope, obj.Inner)
+		if obj.Abort != nil {
+			c.resolveExpr(scope, obj.Abort)
+		}
+		if obj.Cleanup != nil {
+			c.resolveStmt(scope, obj.Cleanup)
+		}
+
+	case *AST.BlockStmt:
+		c.resolveBlock(scope, obj)
+	}
+}
+
+func (c *checker) resolveExpr(scope *AST.Scope, expr AST.Expr) {
+	switch obj := expr.(type) {
+	case *AST.Ident:
+		c.resolveIdent(scope, obj)
+		c.checkNotInstance(obj)
+	case *AST.ParenExpr:
+		c.resolveExpr(scope, obj.X)
+	case *AST.UnaryExpr:
+		c.resolveExpr(scope, obj.X)
+	case *AST.MathExpr:
+		c.resolveExpr(scope, obj.LHS)
+		c.resolveExpr(scope, obj.RHS)
+	case *AST.IndexExpr:
+		c.resolveExpr(scope, obj.X)
+		c.resolveExpr(scope, obj.Index)
+	case *AST.SliceExpr:
+		c.resolveExpr(scope, obj.X)
+		c.resolveExpr(scope, obj.Left)
+		c.resolveExpr(scope, obj.Right)
+	case *AST.ConcatExpr:
+		for _, part := range obj.Parts {
+			c.resolveExpr(scope, part)
+		}
+	case *AST.ReplicateExpr:
+		c.resolveExpr(scope, obj.Count)
+		c.resolveExpr(scope, obj.X)
+	case *AST.CallExpr:
+		c.resolveCall(obj)
+		for _, arg := range obj.Args {
+			c.resolveExpr(scope, arg)
+		}
+	}
+}
+
+// Only built in functions can be called
+func (c *checker) resolveCall(call *AST.CallExpr) {
+	end := [2]int{call.Pos[0], call.Pos[1] + len(call.Fn)}
+
+	fn, ok := AST.Builtins[call.Fn]
+	if !ok {
+		diag := D.Errorf(D.UnknownFunction, call.Pos, end, "Unknown function %s", call.Fn)
+		if suggestion := closestName(call.Fn, AST.BuiltinNames()); suggestion != "" {
+			diag = diag.WithNote("did you mean %s?", suggestion)
+		}
+		c.report(diag)
+		return
+	}
+
+	if !fn.Accepts(len(call.Args)) {
+		expected := fmt.Sprintf("%d argument", fn.MinArgs)
+		if fn.MinArgs != 1 {
+			expected += "s"
+		}
+		if fn.MaxArgs < 0 {
+			expected = "at least " + expected
+		}
+		c.report(D.Errorf(D.ArgumentCount, call.Pos, end, "%s takes %s, not %d", call.Fn, expected, len(call.Args)))
+	}
+}
+
+// The width, clock and initial value of a signal
+func (c *checker) resolveSignal(scope *AST.Scope, decl *AST.SignalDecl) {
+	if decl.WidthExpr != nil {
+		c.resolveExpr(scope, decl.WidthExpr)
+	}
+	if decl.Clock != nil {
+		c.resolveClock(scope, &decl.Clock.Name)
+	}
+	if decl.Init != nil {
+		c.resolveExpr(scope, decl.Init)
+	}
+}
+
+func (c *checker) resolveValue(scope *AST.Scope, decl *AST.ValueDecl) {
+	//The constant isn't visible from its own declaration
+	if decl.WidthExpr != nil {
+		c.resolveExpr(scope, decl.WidthExpr)
+	}
+	c.resolveExpr(scope, decl.Value)
+	c.declare(scope, &decl.Name, decl, AST.Const)
+}
+
+func (c *checker) resolveClock(scope *AST.Scope, id *AST.Ident) {
+	c.resolveIdent(scope, id)
+	if id.Obj != nil && id.Obj.Kind == AST.Const {
+		pos := id.Obj.GetPos()
+		c.report(D.Errorf(D.ConstantMisuse, id.Pos, identEnd(id), "%s is a constant, so it can't be used as a clock", id.Name).
+			WithNote("%s is declared at %d:%d", id.Name, pos[0], pos[1]))
+	}
+}
+
+// An instance only has ports, so its name can't be read or assigned like a signal
+func (c *checker) checkNotInstance(id *AST.Ident) {
+	if id.Obj != nil && id.Obj.Kind == AST.Instance {
+		pos := id.Obj.GetPos()
+		c.report(D.Errorf(D.InstanceMisuse, id.Pos, identEnd(id), "%s is an instance, so it can't be used as a signal", id.Name).
+			WithNote("%s is declared at %d:%d; connect a signal to one of its ports instead", id.Name, pos[0], pos[1]))
+	}
+}
+
+// The busy and done wires of a sequence must be signals
+func (c *checker) resolveStatus(scope *AST.Scope, id *AST.Ident) {
+	c.resolveIdent(scope, id)
+	c.checkNotInstance(id)
+	if id.Obj != nil && id.Obj.Kind == AST.Const {
+		pos := id.Obj.GetPos()
+		c.report(D.Errorf(D.ConstantMisuse, id.Pos, identEnd(id), "%s is a constant, so a sequence can't drive it", id.Name).
+			WithNote("%s is declared at %d:%d", id.Name, pos[0], pos[1]))
+	}
+}
+
+func (c *checker) declare(scope *AST.Scope, name *AST.Ident, decl AST.Decl, kind AST.ObjKind) {
+	obj := &AST.Object{Kind: kind, Name: name.Name, Decl: decl}
+	name.Obj = obj
+
+	if prev := scope.Insert(obj); prev != nil {
+		pos := prev.GetPos()
+		c.report(D.Errorf(D.DuplicateName, name.Pos, identEnd(name), "%s is already declared", name.Name).
+			WithNote("previous declaration of %s at %d:%d", name.Name, pos[0], pos[1]))
+		return
+	}
+
+	if scope.Outer != nil {
+		if outer := scope.Outer.Resolve(name.Name); outer != nil {
+			pos := outer.GetPos()
+			c.report(D.Warningf(D.ShadowedName, name.Pos, identEnd(name), "%s shadows the %s declared at %d:%d",
+				name.Name, strings.ToLower(outer.Kind.String()), pos[0], pos[1]))
+		}
+	}
+}
+
+func (c *checker) resolveIdent(scope *AST.Scope, id *AST.Ident) {
+	id.Obj = scope.Resolve(id.Name)
+	if id.Obj != nil {
+		return
+	}
+
+	diag := D.Errorf(D.UndeclaredName, id.Pos, identEnd(id), "Undeclared name %s", id.Name)
+	if suggestion := closestName(id.Name, scope.Names()); suggestion != "" {
+		diag = diag.WithNote("did you mean %s?", suggestion)
+	}
+	c.report(diag)
+}
@@ -0,0 +1,208 @@
+package Semantic
+
+import (
+	"fmt"
+	"math/big"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Each statement of a sequential block is a step, which lasts at least one clock.
+// A wait is a step of its own that holds the sequence:
+//
+//	wait Ready      until a clock where Ready is set, which can be the first clock of the step
+//	wait 10         for exactly 10 clocks, as the value is a constant
+//
+// Loops repeat a number of steps, with every statement of their body being a step:
+//
+//	while Busy { ... }      checks Busy before each pass, without taking a clock to do so
+//	repeat 4 { ... }        makes exactly 4 passes, as the count is a constant
+//
+// As a while loop can be skipped without taking a clock, the body of every loop needs a step
+// that can't be skipped. Otherwise it could go around forever within a single clock.
+//
+// Waits and loops only make sense as a step, so they can't be used in a parallel block or a condition.
+// A parallel block can still wait by nesting a sequence within it, @(Clk) { wait Ready }
+//
+// A sequence runs once out of reset, unless it has clauses before its block:
+//
+//	when Start      waits in idle until a clock where Start is set, and goes back to idle once it is done
+//	once            only runs the first time it is started
+//	loop            starts over as soon as it is done, rather than going back to idle
+//	busy B, done D  drives the wire B while it is running, and D on the clock it finishes
+//
+// A nested sequence is started by the sequence around it, so only the outermost one can have a start condition or restart.
+//
+// An abort after the block stops the sequence on any clock where its condition is set, skipping the rest of the steps:
+//
+//	abort when Error            goes straight to idle
+//	abort when Error { ... }    runs the steps of the cleanup block first, which can't be aborted
+//
+// Aborting a sequence stops the sequences nested within it as well, without running their cleanup.
+
+// Longest wait in clocks, and the most passes of a repeat loop
+const maxWait = 1 << 32
+
+// Checks that the statements which control a sequence are only used as its steps
+func CheckSequences(tree []AST.AST) []D.Diagnostic {
+	c := &checker{}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			c.checkPlacement(&mod.Block, false, "outside of any sequence", false)
+		}
+	}
+
+	return c.diagnostics
+}
+
+// step is true for the steps of a sequence, otherwise within describes where the statement is
+// and parallel is true if that is within a parallel block of a sequence
+func (c *checker) checkPlacement(stmt AST.Stmt, step bool, within string, parallel bool) {
+	switch obj := stmt.(type) {
+	case *AST.WaitStmt:
+		if !step {
+			c.reportNotStep("wait", obj.Pos, within, parallel)
+		}
+
+	case *AST.LoopStmt:
+		kind := loopKind(obj)
+		if !step {
+			c.reportNotStep(kind, obj.Pos, within, parallel)
+		}
+		if skippable(obj.Body) {
+			diag := D.Errorf(D.EmptyLoop, obj.Pos, obj.Pos, "The body of the %s loop needs a step that always takes a clock", kind)
+			if len(steps(obj.Body)) > 0 {
+				diag = diag.WithNote("a while loop is skipped without taking a clock when its condition isn't set, and every step of the body can be skipped that way")
+			}
+			c.report(diag)
+		}
+		for _, inner := range steps(obj.Body) {
+			c.checkPlacement(inner, true, "", false)
+		}
+
+	case *AST.SequenceStmt:
+		if (step || parallel) && (obj.Start != nil || obj.Restart != AST.Rearm) {
+			c.report(D.Errorf(D.NestedStart, obj.StartPos, obj.StartPos, "A nested sequence can't have a start condition or restart").
+				WithNote("it is started each time the sequence around it gets to it"))
+		}
+		if obj.Restart == AST.Loop && skippable(obj.Inner) {
+			c.report(D.Errorf(D.EmptyLoop, obj.StartPos, obj.StartPos, "A sequence that loops needs a step that always takes a clock"))
+		}
+		for _, inner := range steps(obj.Inner) {
+			c.checkPlacement(inner, true, "", false)
+		}
+		if obj.Cleanup != nil {
+			for _, inner := range steps(obj.Cleanup) {
+				c.checkPlacement(inner, true, "", false)
+			}
+		}
+		c.checkAbort(obj)
+
+	case *AST.BlockStmt:
+		if step {
+			within = fmt.Sprintf("inside the parallel block at %d:%d", obj.StartPos[0], obj.StartPos[1])
+			parallel = true
+		}
+		for _, inner := range obj.StmtList {
+			c.checkPlacement(inner, false, within, parallel)
+		}
+
+	case *AST.IfStmt:
+		what := fmt.Sprintf("inside the if at %d:%d", obj.Pos[0], obj.Pos[1])
+		c.checkPlacement(obj.Body, false, what, false)
+		if obj.Else != nil {
+			c.checkPlacement(obj.Else, false, what, false)
+		}
+
+	case *AST.SwitchStmt:
+		what := fmt.Sprintf("inside the switch at %d:%d", obj.Pos[0], obj.Pos[1])
+		for _, arm := range obj.Cases {
+			c.checkPlacement(arm.Body, false, what, false)
+		}
+	}
+}
+
+func (c *checker) reportNotStep(what string, pos [2]int, within string, parallel bool) {
+	diag := D.Errorf(D.SequenceOnly, pos, pos, "%s can only be a step of a sequence, but it is %s", what, within)
+	if parallel {
+		diag = diag.WithNote("to use it within a parallel block, nest a sequence inside of it")
+	}
+	c.report(diag)
+}
+
+// The steps of a sequential block
+func steps(stmt AST.Stmt) []AST.Stmt {
+	if blk, ok := stmt.(*AST.BlockStmt); ok {
+		return blk.StmtList
+	}
+	return []AST.Stmt{stmt}
+}
+
+// The wires driven with the status of a sequence
+func statusWires(seq *AST.SequenceStmt) []*AST.Ident {
+	var wires []*AST.Ident
+	if seq.Busy != nil {
+		wires = append(wires, seq.Busy)
+	}
+	if seq.Done != nil {
+		wires = append(wires, seq.Done)
+	}
+	return wires
+}
+
+func loopKind(loop *AST.LoopStmt) string {
+	if loop.Cond != nil {
+		return "while"
+	}
+	return "repeat"
+}
+
+// Whether every step of a sequential block can be skipped without taking a clock
+func skippable(stmt AST.Stmt) bool {
+	for _, step := range steps(stmt) {
+		loop, ok := step.(*AST.LoopStmt)
+		if !ok || (loop.Cond == nil && !skippable(loop.Body)) {
+			return false
+		}
+	}
+	return true
+}
+
+// A wait on a constant waits for that many clocks, anything else is a condition
+func (c *checker) foldWait(wait *AST.WaitStmt) {
+	wait.X = c.foldExpr(wait.X)
+
+	lit, ok := wait.X.(*AST.Literal)
+	if !ok {
+		return
+	}
+	wait.Cycles = true
+	if lit.Value.Cmp(big.NewInt(1)) < 0 || lit.Value.Cmp(big.NewInt(maxWait)) > 0 {
+		c.report(D.Errorf(D.InvalidConstant, lit.Pos, lit.Pos, "A wait must be between 1 and %d clocks, not %s", int64(maxWait), lit.Value))
+	}
+}
+
+// The count of a repeat loop must be a constant
+func (c *checker) foldLoop(loop *AST.LoopStmt) {
+	if loop.Cond != nil {
+		loop.Cond = c.foldExpr(loop.Cond)
+	} else {
+		reported := len(c.diagnostics)
+		loop.Count = c.foldExpr(loop.Count)
+
+		lit, ok := loop.Count.(*AST.Literal)
+		pos := loop.Count.GetPos()
+		switch {
+		case !ok && len(c.diagnostics) > reported:
+			//Couldn't be evaluated, which has already been reported
+		case !ok:
+			c.report(D.Errorf(D.NotConstant, pos, pos, "The count of a repeat loop must be a constant"))
+		case lit.Value.Cmp(big.NewInt(1)) < 0 || lit.Value.Cmp(big.NewInt(maxWait)) > 0:
+			c.report(D.Errorf(D.InvalidConstant, pos, pos, "A repeat loop must run between 1 and %d times, not %s", int64(maxWait), lit.Value))
+		}
+	}
+
+	loop.Body = c.foldStmt(loop.Body)
+}
@@ -0,0 +1,115 @@
+package Semantic
+
+import (
+	"math/big"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// A module with parameters is specialized for every distinct set of values it is instantiated with,
+// rather than being emitted with verilog parameters. Each specialization is a copy of the module
+// as it was written, with its parameters replaced by their values, added to the tree as a module of its own.
+// Instances that keep every default use the module itself.
+// The templates are copies of the modules with parameters taken before anything is folded
+
+func (c *checker) startSpecializing(tree []AST.AST) {
+	c.templates = map[string]AST.ModuleDecl{}
+	c.specializations = map[string]*AST.ModuleDecl{}
+	c.specialized = nil
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok && len(mod.Consts) > 0 {
+			c.templates[mod.Name.Name] = AST.CloneModule(mod)
+		}
+	}
+}
+
+func (c *checker) foldInstance(inst *AST.InstanceDecl) {
+	values := map[string]*big.Int{}
+
+	for i := range inst.Conns {
+		conn := &inst.Conns[i]
+		reported := len(c.diagnostics)
+		conn.Value = c.foldExpr(conn.Value)
+
+		if conn.Port.Obj == nil || conn.Port.Obj.Kind != AST.Const {
+			continue
+		}
+		lit, ok := conn.Value.(*AST.Literal)
+		pos := conn.Value.GetPos()
+		if !ok {
+			if len(c.diagnostics) == reported {
+				c.report(D.Errorf(D.NotConstant, pos, pos, "Parameter %s of %s must be a constant", conn.Port.Name, inst.Name.Name))
+			}
+			continue
+		}
+
+		//The module may not have been folded yet
+		decl := conn.Port.Obj.Decl.(*AST.ValueDecl)
+		if decl.WidthExpr != nil {
+			width, err := AST.Eval(decl.WidthExpr)
+			if err == nil && (lit.Value.Sign() < 0 || big.NewInt(int64(lit.Value.BitLen())).Cmp(width) > 0) {
+				c.report(D.Errorf(D.ValueOverflow, pos, pos, "%s does not fit in parameter %s of %s, which is %s bits wide",
+					lit.Value, conn.Port.Name, inst.Name.Name, width))
+				continue
+			}
+		}
+		values[conn.Port.Name] = lit.Value
+	}
+
+	if len(values) > 0 {
+		c.specialize(inst, values)
+	}
+}
+
+// Points the instance at the specialization of its module for the given parameter values
+func (c *checker) specialize(inst *AST.InstanceDecl, values map[string]*big.Int) {
+	tmpl, ok := c.templates[inst.Def.Name.Name]
+	if !ok {
+		return
+	}
+
+	//Every parameter is part of the name, so each set of values has its own
+	same := true
+	name := tmpl.Name.Name
+	for _, value := range tmpl.Consts {
+		def, err := AST.Eval(value.Value)
+		if err != nil {
+			//Reported when the module itself is folded
+			return
+		}
+		val, ok := values[value.Name.Name]
+		if !ok {
+			val = def
+		}
+		same = same && val.Cmp(def) == 0
+		values[value.Name.Name] = val
+		name += "_" + value.Name.Name + "_" + strings.Replace(val.String(), "-", "n", 1)
+	}
+	if same {
+		return
+	}
+
+	spec := c.specializations[name]
+	if spec == nil {
+		clone := AST.CloneModule(tmpl)
+		clone.Name.Name = name
+		for i := range clone.Consts {
+			decl := &clone.Consts[i]
+			val := values[decl.Name.Name]
+			decl.Value = &AST.Literal{Pos: decl.Value.GetPos(), Kind: AST.Decimal, Text: val.String(), Value: val}
+		}
+
+		spec = &clone
+		c.specializations[name] = spec
+		c.foldModule(spec)
+		c.specialized = append(c.specialized, *spec)
+	}
+
+	inst.Def = spec
+	for i := range inst.Conns {
+		inst.Conns[i].Port.Obj = portObject(spec, inst.Conns[i].Port.Name)
+	}
+}
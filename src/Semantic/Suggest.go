@@ -0,0 +1,50 @@
+package Semantic
+
+import "strings"
+
+// The name that is most likely to have been meant instead of a misspelt one.
+// Returns "" if none of the names are close enough to be a typo
+func closestName(name string, names []string) string {
+	best := ""
+	bestDist := (len(name)+1)/3 + 1
+	for _, candidate := range names {
+		dist := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if dist < bestDist {
+			best, bestDist = candidate, dist
+		}
+	}
+	return best
+}
+
+// Number of single character insertions, deletions or substitutions to turn a into b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
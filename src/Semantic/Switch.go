@@ -0,0 +1,246 @@
+package Semantic
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// The arms of a switch are tried in order, so a value matching more than one arm runs the first of them.
+// The default arm is only taken when no other arm matches, wherever it is written.
+// Values can use ? for don't care bits, which match both a 0 and a 1.
+// A switch making combinational assignments needs an arm for every value of its selector,
+// either by listing them all or with a default arm, as the signals would otherwise be latches.
+// Signals declared as latches are the exception
+
+// Number of values left out of a switch that are listed when reporting it
+const missingLimit = 4
+
+// Checks the arms of every switch, recording whether they cover every value
+// and whether their order matters for the verilog backend
+func CheckSwitches(tree []AST.AST) []D.Diagnostic {
+	c := &checker{}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			eachStmt(&mod.Block, func(stmt AST.Stmt) {
+				if sw, ok := stmt.(*AST.SwitchStmt); ok {
+					c.checkSwitch(sw)
+				}
+			})
+		}
+	}
+
+	return c.diagnostics
+}
+
+// A value of a switch arm, the bits set in Mask match anything
+type pattern struct {
+	Value, Mask *big.Int
+	Arm         int
+	Expr        AST.Expr
+}
+
+// Whether some value matches both patterns
+func (p pattern) intersects(other pattern) bool {
+	differ := new(big.Int).Xor(p.Value, other.Value)
+	differ.AndNot(differ, p.Mask)
+	differ.AndNot(differ, other.Mask)
+	return differ.Sign() == 0
+}
+
+// Whether every value matching other also matches p
+func (p pattern) covers(other pattern) bool {
+	return p.intersects(other) && new(big.Int).AndNot(other.Mask, p.Mask).Sign() == 0
+}
+
+func (p pattern) text(width int) string {
+	if p.Mask.Sign() == 0 {
+		return p.Value.String()
+	}
+	var str strings.Builder
+	fmt.Fprintf(&str, "%d'b", width)
+	for bit := width - 1; bit >= 0; bit-- {
+		switch {
+		case p.Mask.Bit(bit) == 1:
+			str.WriteByte('?')
+		case p.Value.Bit(bit) == 1:
+			str.WriteByte('1')
+		default:
+			str.WriteByte('0')
+		}
+	}
+	return str.String()
+}
+
+// Parts of the cube that no pattern matches, up to limit of them
+func uncovered(patterns []pattern, cube pattern, limit int) []pattern {
+	var inside []pattern
+	for _, p := range patterns {
+		if p.intersects(cube) {
+			if p.covers(cube) {
+				return nil
+			}
+			inside = append(inside, p)
+		}
+	}
+	if len(inside) == 0 {
+		return []pattern{cube}
+	}
+
+	//No single pattern covers the cube, so it is split on its highest don't care bit
+	bit := cube.Mask.BitLen() - 1
+	var missing []pattern
+	for _, set := range []uint{0, 1} {
+		half := pattern{Value: new(big.Int).SetBit(cube.Value, bit, set), Mask: new(big.Int).SetBit(cube.Mask, bit, 0)}
+		missing = append(missing, uncovered(inside, half, limit-len(missing))...)
+		if len(missing) >= limit {
+			break
+		}
+	}
+	return missing
+}
+
+func (c *checker) checkSwitch(sw *AST.SwitchStmt) {
+	width := widthOf(sw.X)
+	all := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(width)), big.NewInt(1))
+
+	var patterns []pattern
+	def := -1
+	for i, arm := range sw.Cases {
+		if arm.IsDefault() {
+			def = i
+			continue
+		}
+
+		for _, value := range arm.Values {
+			lit, ok := value.(*AST.Literal)
+			if !ok {
+				//Already reported as not being a constant
+				continue
+			}
+			p := pattern{Value: lit.Value, Mask: new(big.Int), Arm: i, Expr: value}
+			if lit.Mask != nil {
+				p.Mask.And(lit.Mask, all)
+			}
+
+			if lit.Value.Sign() < 0 || lit.Value.BitLen() > width {
+				c.report(D.Errorf(D.ValueOverflow, lit.Pos, lit.Pos, "%s can never match %s, which is %d bits wide",
+					lit.Text, sw.X, width))
+				continue
+			}
+			if len(uncovered(patterns, p, 1)) == 0 {
+				c.report(D.Warningf(D.UnreachableArm, lit.Pos, lit.Pos, "%s is already matched by an earlier arm, so it is never used", lit.Text).
+					WithNote("%s", firstMatch(patterns, p)))
+			}
+
+			for _, other := range patterns {
+				if other.Arm != i && other.intersects(p) {
+					sw.Overlapping = true
+				}
+			}
+			patterns = append(patterns, p)
+		}
+	}
+
+	missing := uncovered(patterns, pattern{Value: new(big.Int), Mask: all}, missingLimit+1)
+	sw.Complete = def >= 0 || len(missing) == 0
+
+	if def >= 0 && len(missing) == 0 {
+		arm := sw.Cases[def]
+		c.report(D.Warningf(D.UnreachableArm, arm.Pos, arm.Pos, "The default arm is never used, every value of %s already has an arm", sw.X))
+	}
+
+	if target := combinationalTarget(sw); !sw.Complete && target != nil {
+		var values []string
+		for i, p := range missing {
+			if i == missingLimit {
+				values = append(values, "...")
+				break
+			}
+			values = append(values, p.text(width))
+		}
+		c.report(D.Errorf(D.IncompleteSwitch, sw.Pos, sw.Pos, "Switch on %s doesn't have an arm for every value, so %s would be a latch",
+			sw.X, target.Name).
+			WithNote("no arm matches %s", strings.Join(values, ", ")).
+			WithNote("add the missing values or a default arm"))
+	}
+}
+
+// Describes the earlier values that together match the pattern
+func firstMatch(patterns []pattern, p pattern) string {
+	for _, other := range patterns {
+		if other.covers(p) {
+			pos := other.Expr.GetPos()
+			return fmt.Sprintf("%s at %d:%d matches it first", other.Expr, pos[0], pos[1])
+		}
+	}
+	return "the values before it match all of it"
+}
+
+// A signal assigned with = within the switch that isn't declared as a latch
+func combinationalTarget(sw *AST.SwitchStmt) *AST.Object {
+	var found *AST.Object
+	eachStmt(sw, func(stmt AST.Stmt) {
+		asmt, ok := stmt.(*AST.AssignStmt)
+		if !ok || asmt.Op != AST.Asmt || found != nil {
+			return
+		}
+		if id := assignTarget(asmt.LHS); id != nil && id.Obj != nil && id.Obj.Signal() != nil && !id.Obj.Signal().Latch {
+			found = id.Obj
+		}
+	})
+	return found
+}
+
+// The values of a switch must be constants.
+// A switch on a constant is replaced by the arm it picks
+func (c *checker) foldSwitch(sw *AST.SwitchStmt) AST.Stmt {
+	sw.X = c.foldExpr(sw.X)
+	for i := range sw.Cases {
+		arm := &sw.Cases[i]
+		for j, value := range arm.Values {
+			reported := len(c.diagnostics)
+			arm.Values[j] = c.foldExpr(value)
+			if _, ok := arm.Values[j].(*AST.Literal); !ok && len(c.diagnostics) == reported {
+				pos := value.GetPos()
+				c.report(D.Errorf(D.NotConstant, pos, pos, "The values of a switch must be constants"))
+			}
+		}
+		arm.Body = c.foldStmt(arm.Body)
+	}
+
+	sel, ok := sw.X.(*AST.Literal)
+	if !ok {
+		return sw
+	}
+
+	var def AST.Stmt
+	for _, arm := range sw.Cases {
+		if arm.IsDefault() {
+			def = arm.Body
+		}
+		for _, value := range arm.Values {
+			if lit, ok := value.(*AST.Literal); ok && matches(sel.Value, lit) {
+				return arm.Body
+			}
+		}
+	}
+	if def != nil {
+		return def
+	}
+	//Inside a sequence the statement still takes a step
+	return &AST.BlockStmt{StartPos: sw.Pos, EndPos: sw.Pos}
+}
+
+// Whether the value matches the value of a switch arm, ignoring its don't care bits
+func matches(value *big.Int, lit *AST.Literal) bool {
+	differ := new(big.Int).Xor(value, lit.Value)
+	if lit.Mask != nil {
+		differ.AndNot(differ, lit.Mask)
+	}
+	return differ.Sign() == 0
+}
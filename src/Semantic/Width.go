@@ -0,0 +1,403 @@
+package Semantic
+
+import (
+	"math/big"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Widths grow so that no bits are lost along the way:
+//
+//	A + B, A - B        the wider operand plus a carry bit
+//	A * B               the sum of the operand widths
+//	A / B, A % B        the width of A, or of B for %
+//	A << N, A <<< N     A plus N bits when N is a literal, otherwise the width of A
+//	A >> B, A >>> B     the width of A
+//	& | ^ ~ unary -     the wider operand
+//	{A, B}, {N{A}}      the sum of the parts, or N times the width of A
+//	comparisons, logical operators and reductions are a single bit
+//
+// Unsized literals take the fewest bits that hold their value.
+// An assignment may drop the carry bit of an addition or subtraction without a warning,
+// so a counter such as Count <- Count + 1 keeps its width
+
+// Computes the width of every expression, sizing signals that were declared without one
+// and reporting assignments that lose bits
+func CheckWidths(tree []AST.AST) []D.Diagnostic {
+	c := &checker{}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			c.inferWidths(&mod.Block)
+
+			for i := range mod.Params {
+				c.checkInit(&mod.Params[i].SignalDecl)
+			}
+			eachStmt(&mod.Block, c.checkStmtWidths)
+		}
+	}
+
+	return c.diagnostics
+}
+
+// Calls fn on the statement and every statement nested within it
+func eachStmt(stmt AST.Stmt, fn func(AST.Stmt)) {
+	fn(stmt)
+
+	switch obj := stmt.(type) {
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			eachStmt(inner, fn)
+		}
+	case *AST.IfStmt:
+		eachStmt(obj.Body, fn)
+		if obj.Else != nil {
+			eachStmt(obj.Else, fn)
+		}
+	case *AST.SwitchStmt:
+		for _, arm := range obj.Cases {
+			eachStmt(arm.Body, fn)
+		}
+	case *AST.LoopStmt:
+		eachStmt(obj.Body, fn)
+	case *AST.SequenceStmt:
+		eachStmt(obj.Inner, fn)
+		if obj.Cleanup != nil {
+			eachStmt(obj.Cleanup, fn)
+		}
+	}
+}
+
+// Signals declared without a width are sized to fit every value assigned to them, carry bits included
+func (c *checker) inferWidths(blk *AST.BlockStmt) {
+	inferred := map[*AST.SignalDecl]bool{}
+	var asmts []*AST.AssignStmt
+	eachStmt(blk, func(stmt AST.Stmt) {
+		switch obj := stmt.(type) {
+		case *AST.DeclStmt:
+			if decl, ok := obj.Decl.(*AST.SignalDecl); ok && decl.Width == 0 {
+				inferred[decl] = true
+			}
+			if inst, ok := obj.Decl.(*AST.InstanceDecl); ok {
+				inferFromOutputs(inst, inferred)
+			}
+		case *AST.AssignStmt:
+			asmts = append(asmts, obj)
+		case *AST.SequenceStmt:
+			//Status wires are a single bit
+			for _, status := range statusWires(obj) {
+				if status.Obj != nil && inferred[status.Obj.Signal()] {
+					decl := status.Obj.Signal()
+					decl.Width = max(decl.Width, 1)
+				}
+			}
+		}
+	})
+
+	//Each round sizes the signals assigned from ones that grew in the round before.
+	//Anything still growing after a round per signal must depend on itself
+	var grew []*AST.AssignStmt
+	for round := 0; round <= len(inferred); round++ {
+		grew = nil
+		for _, asmt := range asmts {
+			ident, ok := asmt.LHS.(*AST.Ident)
+			if !ok || ident.Obj == nil || !inferred[ident.Obj.Signal()] {
+				continue
+			}
+			decl := ident.Obj.Signal()
+			if width := widthOf(asmt.RHS); width > decl.Width {
+				decl.Width = width
+				grew = append(grew, asmt)
+			}
+		}
+		if len(grew) == 0 {
+			return
+		}
+	}
+
+	reported := map[*AST.SignalDecl]bool{}
+	for _, asmt := range grew {
+		decl := asmt.LHS.(*AST.Ident).Obj.Signal()
+		if !reported[decl] {
+			reported[decl] = true
+			c.report(D.Errorf(D.UninferredWidth, decl.Name.Pos, identEnd(&decl.Name),
+				"The width of %s can't be inferred, as it grows with every assignment", decl.Name.Name).
+				WithNote("give %s an explicit width", decl.Name.Name))
+		}
+	}
+}
+
+// A signal connected to an output of an instance is at least as wide as the output
+func inferFromOutputs(inst *AST.InstanceDecl, inferred map[*AST.SignalDecl]bool) {
+	for _, conn := range inst.Conns {
+		id, ok := conn.Value.(*AST.Ident)
+		if !ok || id.Obj == nil || !inferred[id.Obj.Signal()] || conn.Port.Obj == nil {
+			continue
+		}
+		if port, ok := conn.Port.Obj.Decl.(*AST.ParamDecl); ok && port.Dir != AST.In {
+			decl := id.Obj.Signal()
+			decl.Width = max(decl.Width, signalWidth(&port.SignalDecl))
+		}
+	}
+}
+
+func (c *checker) checkStmtWidths(stmt AST.Stmt) {
+	switch obj := stmt.(type) {
+	case *AST.DeclStmt:
+		if decl, ok := obj.Decl.(*AST.SignalDecl); ok {
+			c.checkInit(decl)
+		}
+		if inst, ok := obj.Decl.(*AST.InstanceDecl); ok {
+			for _, conn := range inst.Conns {
+				widthOf(conn.Value)
+				c.checkOperands(conn.Value)
+			}
+		}
+
+	case *AST.AssignStmt:
+		width := widthOf(obj.LHS)
+		c.checkAssign(obj.RHS, width, obj.LHS.String(), obj.Pos)
+		c.checkOperands(obj.LHS)
+		c.checkOperands(obj.RHS)
+
+	case *AST.IfStmt:
+		widthOf(obj.Cond)
+		c.checkOperands(obj.Cond)
+
+	case *AST.SwitchStmt:
+		widthOf(obj.X)
+		c.checkOperands(obj.X)
+
+	case *AST.WaitStmt:
+		widthOf(obj.X)
+		c.checkOperands(obj.X)
+
+	case *AST.LoopStmt:
+		if obj.Cond != nil {
+			widthOf(obj.Cond)
+			c.checkOperands(obj.Cond)
+		}
+
+	case *AST.SequenceStmt:
+		if obj.Start != nil {
+			widthOf(obj.Start)
+			c.checkOperands(obj.Start)
+		}
+		if obj.Abort != nil {
+			widthOf(obj.Abort)
+			c.checkOperands(obj.Abort)
+		}
+	}
+}
+
+func (c *checker) checkInit(decl *AST.SignalDecl) {
+	if decl.Init != nil {
+		c.checkAssign(decl.Init, signalWidth(decl), decl.Name.Name, decl.Init.GetPos())
+		c.checkOperands(decl.Init)
+	}
+}
+
+// Reports a value that is wider than what it is being assigned to
+func (c *checker) checkAssign(value AST.Expr, width int, name string, pos [2]int) {
+	if lit, ok := stripParens(value).(*AST.Literal); ok && lit.Width == 0 {
+		if lit.Value.BitLen() > width {
+			c.report(D.Errorf(D.ValueOverflow, lit.Pos, lit.Pos, "%s does not fit in %s, which is %d bits wide", lit.Text, name, width))
+		}
+		return
+	}
+
+	if got := assignedWidth(value); got > width {
+		c.report(D.Warningf(D.WidthTruncation, pos, pos,
+			"Assigning %d bits to %s, which is %d bits wide, drops the upper bits", got, name, width))
+	}
+}
+
+// Reports bitwise operations and comparisons between values of different widths,
+// where the narrower one is silently zero extended
+func (c *checker) checkOperands(expr AST.Expr) {
+	switch obj := expr.(type) {
+	case *AST.ParenExpr:
+		c.checkOperands(obj.X)
+	case *AST.UnaryExpr:
+		c.checkOperands(obj.X)
+	case *AST.IndexExpr:
+		c.checkOperands(obj.Index)
+	case *AST.SliceExpr:
+		c.checkOperands(obj.Left)
+		c.checkOperands(obj.Right)
+	case *AST.ConcatExpr:
+		for _, part := range obj.Parts {
+			c.checkOperands(part)
+		}
+	case *AST.ReplicateExpr:
+		c.checkOperands(obj.X)
+
+	case *AST.MathExpr:
+		c.checkOperands(obj.LHS)
+		c.checkOperands(obj.RHS)
+
+		//A left shift widens its result by the amount, which can't be more than the widest signal
+		if obj.Op == AST.LShift || obj.Op == AST.ArithLShift {
+			if lit, ok := stripParens(obj.RHS).(*AST.Literal); ok && lit.Value.Cmp(big.NewInt(maxWidth)) > 0 {
+				c.report(D.Errorf(D.InvalidConstant, lit.Pos, lit.Pos, "A shift must be by at most %d bits, not %s", maxWidth, lit.Value))
+			}
+		}
+
+		lhs, rhs := obj.LHS.ResultWidth(), obj.RHS.ResultWidth()
+		if lhs == rhs || isUnsized(obj.LHS) || isUnsized(obj.RHS) {
+			return
+		}
+		switch obj.Op {
+		case AST.Equals, AST.NotEquals, AST.Less, AST.LessEq, AST.Greater, AST.GreaterEq:
+			c.report(D.Warningf(D.WidthMismatch, obj.Pos, obj.Pos, "Comparing values of %d and %d bits", lhs, rhs))
+		case AST.BitAnd, AST.BitOr, AST.BitXor:
+			c.report(D.Warningf(D.WidthMismatch, obj.Pos, obj.Pos, "Bitwise operation on values of %d and %d bits", lhs, rhs))
+		}
+	}
+}
+
+// Computes and records the width of an expression and everything within it
+func widthOf(expr AST.Expr) int {
+	width := 1
+
+	switch obj := expr.(type) {
+	case *AST.Ident:
+		if obj.Obj != nil && obj.Obj.Signal() != nil {
+			width = signalWidth(obj.Obj.Signal())
+		}
+	case *AST.Literal:
+		width = literalWidth(obj)
+	case *AST.ParenExpr:
+		width = widthOf(obj.X)
+	case *AST.UnaryExpr:
+		inner := widthOf(obj.X)
+		if obj.Op == AST.Negate || obj.Op == AST.BitNot {
+			width = inner
+		}
+	case *AST.MathExpr:
+		width = mathWidth(obj)
+	case *AST.IndexExpr:
+		widthOf(obj.X)
+		widthOf(obj.Index)
+	case *AST.SliceExpr:
+		width = sliceWidth(obj)
+	case *AST.ConcatExpr:
+		width = 0
+		for _, part := range obj.Parts {
+			width += widthOf(part)
+		}
+	case *AST.ReplicateExpr:
+		widthOf(obj.Count)
+		width = widthOf(obj.X)
+		if count, ok := literalValue(obj.Count); ok {
+			width *= int(count)
+		}
+	}
+
+	expr.SetResultWidth(width)
+	return width
+}
+
+func mathWidth(expr *AST.MathExpr) int {
+	lhs, rhs := widthOf(expr.LHS), widthOf(expr.RHS)
+
+	switch expr.Op {
+	case AST.Add, AST.Sub:
+		return max(lhs, rhs) + 1
+	case AST.Multi:
+		return lhs + rhs
+	case AST.Div, AST.RShift, AST.ArithRShift:
+		return lhs
+	case AST.Mod:
+		return rhs
+	case AST.LShift, AST.ArithLShift:
+		//Shifts by more than the widest signal have been reported by checkOperands
+		if n, ok := literalValue(expr.RHS); ok && n >= 0 && n <= maxWidth {
+			return lhs + int(n)
+		}
+		return lhs
+	case AST.BitAnd, AST.BitOr, AST.BitXor:
+		return max(lhs, rhs)
+	}
+	//Comparisons and logical operators
+	return 1
+}
+
+func sliceWidth(slice *AST.SliceExpr) int {
+	width := widthOf(slice.X)
+	widthOf(slice.Left)
+	widthOf(slice.Right)
+
+	if slice.Kind == AST.SliceRange {
+		high, highOk := literalValue(slice.Left)
+		low, lowOk := literalValue(slice.Right)
+		if highOk && lowOk && high >= low {
+			return int(high-low) + 1
+		}
+		return width
+	}
+
+	if n, ok := literalValue(slice.Right); ok && n > 0 {
+		return int(n)
+	}
+	return width
+}
+
+// The width of a value being assigned, without the carry bit of an addition or subtraction
+func assignedWidth(expr AST.Expr) int {
+	width := widthOf(expr)
+	if math, ok := stripParens(expr).(*AST.MathExpr); ok && (math.Op == AST.Add || math.Op == AST.Sub) {
+		return width - 1
+	}
+	return width
+}
+
+// Signals declared without a width are a single bit until they are inferred
+func signalWidth(decl *AST.SignalDecl) int {
+	if decl.Width == 0 {
+		return 1
+	}
+	return decl.Width
+}
+
+func literalWidth(lit *AST.Literal) int {
+	if lit.Width > 0 {
+		return lit.Width
+	}
+	return max(lit.Value.BitLen(), 1)
+}
+
+func isUnsized(expr AST.Expr) bool {
+	lit, ok := stripParens(expr).(*AST.Literal)
+	return ok && lit.Width == 0
+}
+
+func stripParens(expr AST.Expr) AST.Expr {
+	for {
+		paren, ok := expr.(*AST.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
+
+// The value of a literal, if the expression is one
+func literalValue(expr AST.Expr) (int64, bool) {
+	lit, ok := stripParens(expr).(*AST.Literal)
+	if !ok || !lit.Value.IsInt64() {
+		return 0, false
+	}
+	return lit.Value.Int64(), true
+}
+
+func max(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,28 @@
+package verilog
+
+import (
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// raised to abandon the current module once an error has been found
+type bailout struct{}
+
+func (gen *generator) displayError(code D.Code, pos [2]int, format string, args ...interface{}) {
+	gen.diagnostics = append(gen.diagnostics, D.Errorf(code, pos, pos, format, args...))
+
+	panic(bailout{})
+}
+
+// generates a module, skipping over the rest of it if an error is found
+func (gen *generator) emitModule(mod AST.ModuleDecl) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+		}
+	}()
+
+	gen.emitModuleDecl(mod)
+}
@@ -0,0 +1,79 @@
+package verilog
+
+import (
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+)
+
+// Parameters have already been folded into the module an instance uses,
+// so only the ports are connected
+func (gen *generator) emitInstance(inst *AST.InstanceDecl, ident int) {
+	var ports []AST.PortConn
+	for _, conn := range inst.Conns {
+		if conn.Port.Obj != nil && conn.Port.Obj.Kind == AST.Port {
+			ports = append(ports, conn)
+		}
+	}
+
+	gen.writeToFile(Indent(ident) + inst.Def.Name.Name + " " + gen.signalName(inst.Name) + " (\n")
+	for i, conn := range ports {
+		str := Indent(ident+1) + "." + conn.Port.Name + "(" + gen.emitExpr(conn.Value) + ")"
+		if i < len(ports)-1 {
+			str += ","
+		}
+		gen.writeToFile(str + "\n")
+	}
+	gen.writeToFile(Indent(ident) + ");\n")
+}
+
+// Whether a connection is to an output of the instance
+func isOutput(conn AST.PortConn) bool {
+	if conn.Port.Obj == nil {
+		return false
+	}
+	port, ok := conn.Port.Obj.Decl.(*AST.ParamDecl)
+	return ok && port.Dir != AST.In
+}
+
+// Orders the tree so every module comes after the modules it instantiates,
+// otherwise keeping the order they were written in
+func dependencyOrder(tree []AST.AST) []AST.AST {
+	var ordered []AST.AST
+	added := map[string]bool{}
+
+	var add func(mod AST.ModuleDecl)
+	add = func(mod AST.ModuleDecl) {
+		if added[mod.Name.Name] {
+			return
+		}
+		added[mod.Name.Name] = true
+		for _, inst := range instancesOf(&mod.Block) {
+			add(*inst.Def)
+		}
+		ordered = append(ordered, mod)
+	}
+
+	for _, elem := range tree {
+		if mod, ok := elem.(AST.ModuleDecl); ok {
+			add(mod)
+		} else {
+			ordered = append(ordered, elem)
+		}
+	}
+	return ordered
+}
+
+// The instances declared directly within a block, or blocks nested in it
+func instancesOf(blk *AST.BlockStmt) []*AST.InstanceDecl {
+	var list []*AST.InstanceDecl
+	for _, stmt := range blk.StmtList {
+		switch obj := stmt.(type) {
+		case *AST.DeclStmt:
+			if inst, ok := obj.Decl.(*AST.InstanceDecl); ok {
+				list = append(list, inst)
+			}
+		case *AST.BlockStmt:
+			list = append(list, instancesOf(obj)...)
+		}
+	}
+	return list
+}
@@ -0,0 +1,233 @@
+package verilog
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Loops are flattened into the steps of the sequence they are in, with the last step of the body
+// going back to the first. Deciding whether to go around again, or whether to enter a while loop
+// at all, doesn't take a clock, so finishing a step can lead to one of several others.
+// Repeat loops count their passes with a counter shared by all of the loops at the same depth,
+// as only one of them can be running at a time
+
+// A loop within a sequence
+type seqLoop struct {
+	Stmt   *AST.LoopStmt
+	First  int // The first step of the body
+	End    int // One past the last step of the body
+	Depth  int // Number of loops it is nested within
+	Passes int // Number of passes a repeat loop makes, or 0 for a while loop
+}
+
+// Where a sequence goes once a step is done
+type transition struct {
+	Updates []string // Counters changed along the way
+	Cond    string   // If set, Then is taken when it holds and Else otherwise
+	Then    *transition
+	Else    *transition
+	Target  int // Step entered, when there is no condition
+}
+
+// The statements of a sequential block
+func seqSteps(stmt AST.Stmt) []AST.Stmt {
+	if blk, ok := stmt.(*AST.BlockStmt); ok {
+		return blk.StmtList
+	}
+	return []AST.Stmt{stmt}
+}
+
+// Adds each statement as a step, with the bodies of loops flattened in place
+func (seq *sequence) addSteps(stmts []AST.Stmt, active bool, depth int) {
+	for _, inner := range stmts {
+		if stmt, ok := inner.(*AST.LoopStmt); ok {
+			loop := &seqLoop{Stmt: stmt, First: len(seq.Steps), Depth: depth}
+			if stmt.Cond == nil {
+				count, ok := stmt.Count.(*AST.Literal)
+				if !ok {
+					seq.displayError(D.UnsupportedConstruct, stmt.Count.GetPos(), "Repeat count must be a literal")
+				}
+				loop.Passes = int(count.Value.Int64())
+			}
+			seq.addSteps(seqSteps(stmt.Body), active, depth+1)
+			loop.End = len(seq.Steps)
+			seq.Loops = append(seq.Loops, loop)
+			continue
+		}
+
+		step := seqStep{}
+		if wait, ok := inner.(*AST.WaitStmt); ok {
+			step.Wait = wait
+		} else {
+			seq.collectStep(seq, &step, inner, active && len(seq.Steps) == 0)
+		}
+		seq.Steps = append(seq.Steps, step)
+		seq.Children = append(seq.Children, step.Children...)
+	}
+}
+
+// Loops whose body ends just before the step, from the innermost out.
+// Only loops nested within fewer than within others are included, if it isn't negative
+func (seq *sequence) loopsEnding(end int, within int) []*seqLoop {
+	var loops []*seqLoop
+	for _, loop := range seq.Loops {
+		if loop.End == end && (within < 0 || loop.Depth < within) {
+			loops = append(loops, loop)
+		}
+	}
+	sort.SliceStable(loops, func(i, j int) bool { return loops[i].Depth > loops[j].Depth })
+	return loops
+}
+
+// Loops whose body starts with the step, from the outermost in.
+// Only loops nested within at least depth others are included
+func (seq *sequence) loopsStarting(first int, depth int) []*seqLoop {
+	var loops []*seqLoop
+	for _, loop := range seq.Loops {
+		if loop.First == first && loop.Depth >= depth {
+			loops = append(loops, loop)
+		}
+	}
+	sort.SliceStable(loops, func(i, j int) bool { return loops[i].Depth < loops[j].Depth })
+	return loops
+}
+
+// Where the sequence goes once step n is done
+func (seq *sequence) after(n int) *transition {
+	return seq.leave(n+1, seq.loopsEnding(n+1, -1))
+}
+
+// Where the sequence goes when it is started
+func (seq *sequence) start() *transition {
+	return seq.enter(0, seq.loopsStarting(0, 0))
+}
+
+// Reaching the end of the bodies of loops, either going around again or moving past them
+func (seq *sequence) leave(end int, loops []*seqLoop) *transition {
+	if len(loops) == 0 {
+		//The cleanup is only run after an abort
+		if end == seq.Cleanup {
+			end = seq.idle()
+		}
+		return seq.enter(end, seq.loopsStarting(end, 0))
+	}
+
+	loop, rest := loops[0], loops[1:]
+	again := seq.enter(loop.First, seq.loopsStarting(loop.First, loop.Depth+1))
+	if loop.Stmt.Cond != nil {
+		return &transition{Cond: seq.emitCondition(loop.Stmt.Cond), Then: again, Else: seq.leave(end, rest)}
+	}
+	if loop.Passes <= 1 {
+		return seq.leave(end, rest)
+	}
+
+	counter := seq.loopCounter(loop.Depth)
+	again.Updates = append([]string{counter + " <= " + counter + " - 1'd1"}, again.Updates...)
+	return &transition{Cond: counter + " != " + seq.loopCount(loop.Depth, 0), Then: again, Else: seq.leave(end, rest)}
+}
+
+// Entering a step at the start of the bodies of loops
+func (seq *sequence) enter(n int, loops []*seqLoop) *transition {
+	if len(loops) == 0 {
+		return &transition{Target: n}
+	}
+
+	loop, rest := loops[0], loops[1:]
+	body := seq.enter(n, rest)
+	if loop.Stmt.Cond != nil {
+		return &transition{Cond: seq.emitCondition(loop.Stmt.Cond), Then: body, Else: seq.leave(loop.End, seq.loopsEnding(loop.End, loop.Depth))}
+	}
+	if loop.Passes > 1 {
+		counter := seq.loopCounter(loop.Depth)
+		body.Updates = append([]string{counter + " <= " + seq.loopCount(loop.Depth, loop.Passes-1)}, body.Updates...)
+	}
+	return body
+}
+
+// Condition for the transition to end up at step n, either "1", "0" or an expression
+func (t *transition) reaches(n int) string {
+	if t.Cond == "" {
+		if t.Target == n {
+			return "1"
+		}
+		return "0"
+	}
+
+	then, els := t.Then.reaches(n), t.Else.reaches(n)
+	switch {
+	case then == els:
+		return then
+	case then == "1" && els == "0":
+		return "(" + t.Cond + ")"
+	case then == "0" && els == "1":
+		return "!(" + t.Cond + ")"
+	case els == "0":
+		return "((" + t.Cond + ") && " + then + ")"
+	case then == "0":
+		return "(!(" + t.Cond + ") && " + els + ")"
+	}
+	return "((" + t.Cond + ") ? " + then + " : " + els + ")"
+}
+
+func (gen *generator) emitTransition(seq *sequence, t *transition, ident int) {
+	for _, update := range t.Updates {
+		gen.writeToFile(Indent(ident) + update + ";\n")
+	}
+
+	if t.Cond == "" {
+		//A sequence that loops never goes idle once it has started
+		if t.Target == seq.idle() && seq.Stmt.Restart == AST.Loop {
+			gen.emitTransition(seq, seq.start(), ident)
+			return
+		}
+		gen.emitSequenceEnter(seq, t.Target, ident)
+		return
+	}
+
+	gen.writeToFile(Indent(ident) + "if (" + t.Cond + ")\n")
+	gen.writeToFile(Indent(ident) + "begin\n")
+	gen.emitTransition(seq, t.Then, ident+1)
+	gen.writeToFile(Indent(ident) + "end\n")
+	gen.writeToFile(Indent(ident) + "else\n")
+	gen.writeToFile(Indent(ident) + "begin\n")
+	gen.emitTransition(seq, t.Else, ident+1)
+	gen.writeToFile(Indent(ident) + "end\n")
+}
+
+func (seq *sequence) loopCounter(depth int) string {
+	return fmt.Sprintf("_seq%d_loop%d", seq.ID, depth)
+}
+
+// The counter of a depth holds the passes left, so it needs to fit one less than the most passes.
+// Returns 0 if no repeat loop at the depth needs one
+func (seq *sequence) loopCounterWidth(depth int) int {
+	most := 0
+	for _, loop := range seq.Loops {
+		if loop.Depth == depth && loop.Passes > most {
+			most = loop.Passes
+		}
+	}
+	if most <= 1 {
+		return 0
+	}
+	return bits.Len(uint(most - 1))
+}
+
+func (seq *sequence) loopCount(depth int, n int) string {
+	return fmt.Sprintf("%d'd%d", seq.loopCounterWidth(depth), n)
+}
+
+// Number of loop counters, one for each depth of loops
+func (seq *sequence) loopDepth() int {
+	depth := 0
+	for _, loop := range seq.Loops {
+		if loop.Depth+1 > depth {
+			depth = loop.Depth + 1
+		}
+	}
+	return depth
+}
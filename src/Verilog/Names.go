@@ -0,0 +1,42 @@
+package verilog
+
+import (
+	"fmt"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Verilog has a single namespace per module, so a signal that shadows another is renamed
+
+func (gen *generator) resetNames() {
+	gen.signalNames = map[*AST.Object]string{}
+	gen.usedNames = map[string]bool{}
+}
+
+// Reserves a name for a signal declared in the module, renaming it if it is already taken
+func (gen *generator) nameSignal(name AST.Ident) {
+	unique := name.Name
+	for i := 1; gen.usedNames[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name.Name, i)
+	}
+	gen.usedNames[unique] = true
+	if unique != name.Name {
+		gen.signalNames[name.Obj] = unique
+	}
+}
+
+func (gen *generator) signalName(name AST.Ident) string {
+	if unique, ok := gen.signalNames[name.Obj]; ok {
+		return unique
+	}
+	return name.Name
+}
+
+// The declaration an identifier was resolved to
+func (gen *generator) signalDecl(name *AST.Ident) *AST.SignalDecl {
+	if name.Obj == nil {
+		gen.displayError(D.UndeclaredSignal, name.Pos, "Undeclared signal %s", name.Name)
+	}
+	return name.Obj.Signal()
+}
@@ -0,0 +1,150 @@
+package verilog
+
+import (
+	"fmt"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Bit selects and slices are checked against the width of the signal they select from.
+// Only literal positions can be checked; anything else is left to the hardware
+
+func (gen *generator) checkSelectStmt(stmt AST.Stmt) {
+	switch obj := stmt.(type) {
+	case *AST.AssignStmt:
+		gen.checkSelectExpr(obj.LHS)
+		gen.checkSelectExpr(obj.RHS)
+	case *AST.IfStmt:
+		gen.checkSelectExpr(obj.Cond)
+		gen.checkSelectStmt(obj.Body)
+		if obj.Else != nil {
+			gen.checkSelectStmt(obj.Else)
+		}
+	case *AST.WaitStmt:
+		gen.checkSelectExpr(obj.X)
+	case *AST.LoopStmt:
+		if obj.Cond != nil {
+			gen.checkSelectExpr(obj.Cond)
+		}
+		gen.checkSelectStmt(obj.Body)
+	case *AST.SwitchStmt:
+		gen.checkSelectExpr(obj.X)
+		for _, arm := range obj.Cases {
+			gen.checkSelectStmt(arm.Body)
+		}
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			gen.checkSelectStmt(inner)
+		}
+	case *AST.SequenceStmt:
+		if obj.Start != nil {
+			gen.checkSelectExpr(obj.Start)
+		}
+		gen.checkSelectStmt(obj.Inner)
+		if obj.Abort != nil {
+			gen.checkSelectExpr(obj.Abort)
+		}
+		if obj.Cleanup != nil {
+			gen.checkSelectStmt(obj.Cleanup)
+		}
+	}
+}
+
+func (gen *generator) checkSelectExpr(expr AST.Expr) {
+	switch obj := expr.(type) {
+	case *AST.ParenExpr:
+		gen.checkSelectExpr(obj.X)
+	case *AST.UnaryExpr:
+		gen.checkSelectExpr(obj.X)
+	case *AST.MathExpr:
+		gen.checkSelectExpr(obj.LHS)
+		gen.checkSelectExpr(obj.RHS)
+	case *AST.ConcatExpr:
+		for _, part := range obj.Parts {
+			gen.checkSelectExpr(part)
+		}
+	case *AST.ReplicateExpr:
+		if _, ok := constant(obj.Count); !ok {
+			gen.displayError(D.NonConstantWidth, obj.Count.GetPos(), "Replication count must be a literal")
+		}
+		gen.checkSelectExpr(obj.X)
+
+	case *AST.IndexExpr:
+		gen.checkSelectExpr(obj.Index)
+		width, name := gen.selectWidth(obj.X)
+		if idx, ok := constant(obj.Index); ok && (idx < 0 || idx >= width) {
+			gen.displayError(D.SelectOutOfRange, obj.Pos, "Bit %d is out of range for %s, which is %d bits wide", idx, name, width)
+		}
+
+	case *AST.SliceExpr:
+		gen.checkSelectExpr(obj.Left)
+		gen.checkSelectExpr(obj.Right)
+		width, name := gen.selectWidth(obj.X)
+		left, leftOk := constant(obj.Left)
+		right, rightOk := constant(obj.Right)
+
+		if obj.Kind == AST.SliceRange {
+			if leftOk && rightOk && left < right {
+				gen.displayError(D.SelectOutOfRange, obj.Pos, "Slice [%d:%d] of %s must be written high bit first", left, right, name)
+			}
+			if (leftOk && left >= width) || (rightOk && right < 0) {
+				gen.displayError(D.SelectOutOfRange, obj.Pos, "Slice [%d:%d] is out of range for %s, which is %d bits wide", left, right, name, width)
+			}
+			return
+		}
+
+		//The width of a +: or -: slice sets the width of the result, so it must be known
+		if !rightOk {
+			gen.displayError(D.NonConstantWidth, obj.Right.GetPos(), "Slice width must be a literal")
+		}
+		if right < 1 {
+			gen.displayError(D.SelectOutOfRange, obj.Right.GetPos(), "Slice width must be at least 1")
+		}
+		if !leftOk {
+			return
+		}
+		low, high := left, left+right-1
+		if obj.Kind == AST.SliceDown {
+			low, high = left-right+1, left
+		}
+		if low < 0 || high >= width {
+			gen.displayError(D.SelectOutOfRange, obj.Pos, "Slice %s is out of range for %s, which is %d bits wide", gen.sliceText(obj), name, width)
+		}
+	}
+}
+
+// The width and name of the signal being selected from
+func (gen *generator) selectWidth(x AST.Expr) (int64, string) {
+	ident, ok := x.(*AST.Ident)
+	if !ok {
+		gen.displayError(D.UnsupportedConstruct, x.GetPos(), "Only signals can be indexed or sliced")
+	}
+	decl := gen.signalDecl(ident)
+	if decl.Width == 0 {
+		return 1, ident.Name
+	}
+	return int64(decl.Width), ident.Name
+}
+
+// The value of a literal, if the expression is one
+func constant(expr AST.Expr) (int64, bool) {
+	if paren, ok := expr.(*AST.ParenExpr); ok {
+		return constant(paren.X)
+	}
+	lit, ok := expr.(*AST.Literal)
+	if !ok || !lit.Value.IsInt64() {
+		return 0, false
+	}
+	return lit.Value.Int64(), true
+}
+
+func (gen *generator) sliceText(slice *AST.SliceExpr) string {
+	return fmt.Sprintf("[%s %s %s]", gen.emitExpr(slice.Left), sliceOperators[slice.Kind], gen.emitExpr(slice.Right))
+}
+
+var sliceOperators = map[AST.SliceKind]string{
+	AST.SliceRange: ":",
+	AST.SliceUp:    "+:",
+	AST.SliceDown:  "-:",
+}
@@ -0,0 +1,428 @@
+package verilog
+
+import (
+	"fmt"
+	"math/bits"
+	"reflect"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Sequences are lowered into one state register per sequential block.
+// Each statement in a sequential block becomes a step that lasts one clock.
+// A parallel block is a single step that lasts until all of the sequences
+// nested within it are done, while its other statements apply on every clock
+// that the step is active.
+// A wait is a step that holds until its condition is set, or for a number of clocks
+// counted down by a counter shared between the waits of the sequence.
+// Loops are flattened into the steps, see Loop.go.
+// A sequence with a start condition waits in idle until it is set. If it only runs once,
+// it waits in a state of its own past idle instead, so that it stays in idle once it is done.
+// The steps of the cleanup of an abort come after the rest, with the last of the others going straight to idle.
+// An abort takes priority over the step it happens in, so none of the step is applied.
+
+// State machine generated from a single sequential block
+type sequence struct {
+	*generator
+	Stmt     *AST.SequenceStmt
+	ID       int
+	Clock    AST.ClockDecl
+	Steps    []seqStep
+	Children []*sequence
+	Loops    []*seqLoop
+	Cleanup  int  // The first step of the cleanup, idle if there is none
+	Active   bool // Whether the sequence is running out of reset
+}
+
+type seqStep struct {
+	Stmts    []AST.Stmt  // Applied on every clock the step is active
+	Children []*sequence // Started when the step is entered
+	Wait     *AST.WaitStmt
+}
+
+func (gen *generator) buildSequence(stmt *AST.SequenceStmt, active bool) *sequence {
+	seq := &sequence{generator: gen, Stmt: stmt, ID: gen.sequenceCount, Clock: stmt.Clk, Active: active}
+	gen.sequenceCount++
+
+	stmts := seqSteps(stmt.Inner)
+
+	//The state out of reset can't depend on the condition of a loop,
+	//so a sequence running from reset that starts with one takes a clock to get to it
+	if _, ok := stmts[0].(*AST.LoopStmt); ok && active {
+		seq.Steps = append(seq.Steps, seqStep{})
+	}
+	seq.addSteps(stmts, active, 0)
+
+	//An empty sequence still takes a clock to run
+	if len(seq.Steps) == 0 {
+		seq.Steps = append(seq.Steps, seqStep{})
+	}
+
+	seq.Cleanup = len(seq.Steps)
+	if stmt.Cleanup != nil {
+		seq.addSteps(seqSteps(stmt.Cleanup), false, 0)
+	}
+
+	return seq
+}
+
+// Gathers the parallel statements of a single step
+func (gen *generator) collectStep(seq *sequence, step *seqStep, stmt AST.Stmt, active bool) {
+	switch obj := stmt.(type) {
+	case *AST.SequenceStmt:
+		if !sameClock(obj.Clk, seq.Clock) {
+			gen.displayError(D.ClockMismatch, obj.StartPos, "Nested sequence must use the clock of its parent (%s)",
+				gen.emitClockEdge(seq.Clock))
+		}
+		step.Children = append(step.Children, gen.buildSequence(obj, active))
+
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			gen.collectStep(seq, step, inner, active)
+		}
+
+	case *AST.AssignStmt, *AST.IfStmt, *AST.SwitchStmt:
+		gen.checkSeqAction(stmt)
+		step.Stmts = append(step.Stmts, stmt)
+
+	default:
+		gen.displayError(D.UnsupportedConstruct, stmt.GetPos(), "Unexpected statement in sequence: %v", reflect.TypeOf(stmt))
+	}
+}
+
+// Only register assignments can be made from within a sequence
+func (gen *generator) checkSeqAction(stmt AST.Stmt) {
+	switch obj := stmt.(type) {
+	case *AST.AssignStmt:
+		if obj.Op != AST.AsmtReg {
+			gen.displayError(D.SequenceAssignment, obj.Pos, "Assignment inside a sequence must be a register assignment (<-)")
+		}
+	case *AST.IfStmt:
+		gen.checkSeqAction(obj.Body)
+		if obj.Else != nil {
+			gen.checkSeqAction(obj.Else)
+		}
+	case *AST.SwitchStmt:
+		for _, arm := range obj.Cases {
+			gen.checkSeqAction(arm.Body)
+		}
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			gen.checkSeqAction(inner)
+		}
+	default:
+		gen.displayError(D.UnsupportedConstruct, stmt.GetPos(), "Unexpected statement in sequence condition: %v", reflect.TypeOf(stmt))
+	}
+}
+
+// Children are listed before their parents
+func (seq *sequence) flatten() []*sequence {
+	var list []*sequence
+	for _, child := range seq.Children {
+		list = append(list, child.flatten()...)
+	}
+	return append(list, seq)
+}
+
+func (seq *sequence) stateName() string {
+	return fmt.Sprintf("_seq%d_state", seq.ID)
+}
+
+// The state one past the last step is used once the sequence is done
+func (seq *sequence) idle() int {
+	return len(seq.Steps)
+}
+
+// The state a sequence with a start condition waits in to be started
+func (seq *sequence) waiting() int {
+	if seq.Stmt.Start != nil && seq.Stmt.Restart == AST.Once {
+		return seq.idle() + 1
+	}
+	return seq.idle()
+}
+
+func (seq *sequence) stateWidth() int {
+	width := 1
+	for (1 << width) <= seq.waiting() {
+		width++
+	}
+	return width
+}
+
+func (seq *sequence) state(n int) string {
+	return fmt.Sprintf("%d'd%d", seq.stateWidth(), n)
+}
+
+func (seq *sequence) counterName() string {
+	return fmt.Sprintf("_seq%d_count", seq.ID)
+}
+
+// Number of clocks the step waits for, or 0 if it isn't a wait for a number of clocks
+func (seq *sequence) cycles(n int) int {
+	wait := seq.Steps[n].Wait
+	if wait == nil || !wait.Cycles {
+		return 0
+	}
+	lit, ok := wait.X.(*AST.Literal)
+	if !ok {
+		seq.displayError(D.UnsupportedConstruct, wait.X.GetPos(), "Number of clocks to wait must be a literal")
+	}
+	return int(lit.Value.Int64())
+}
+
+// The counter holds the clocks left in a wait, so it needs to fit one less than the longest one.
+// Returns 0 if there is no wait long enough to need it
+func (seq *sequence) counterWidth() int {
+	longest := 0
+	for n := range seq.Steps {
+		if cycles := seq.cycles(n); cycles > longest {
+			longest = cycles
+		}
+	}
+	if longest <= 1 {
+		return 0
+	}
+	return bits.Len(uint(longest - 1))
+}
+
+func (seq *sequence) count(n int) string {
+	return fmt.Sprintf("%d'd%d", seq.counterWidth(), n)
+}
+
+// Condition for the step to be able to progress on this clock
+func (seq *sequence) stepDone(n int) string {
+	var conds []string
+	for _, child := range seq.Steps[n].Children {
+		conds = append(conds, child.finishing())
+	}
+	if wait := seq.Steps[n].Wait; wait != nil && !wait.Cycles {
+		conds = append(conds, "("+seq.emitCondition(wait.X)+")")
+	}
+	if seq.cycles(n) > 1 {
+		conds = append(conds, seq.counterName()+" == "+seq.count(0))
+	}
+	return strings.Join(conds, " && ")
+}
+
+// Condition for the sequence to be done after this clock
+func (seq *sequence) finishing() string {
+	conds := append([]string{seq.stateName() + " == " + seq.state(seq.idle())}, seq.completing(seq.idle())...)
+	return "(" + strings.Join(conds, " || ") + ")"
+}
+
+// Conditions for the sequence to finish on this clock, one for each step before end it can finish from.
+// Without loops only the last step leads to the end, otherwise any step can that is followed by loops that are skipped
+func (seq *sequence) completing(end int) []string {
+	var conds []string
+	for n := range seq.Steps[:end] {
+		reaches := seq.after(n).reaches(seq.idle())
+		if reaches == "0" {
+			continue
+		}
+
+		parts := []string{seq.stateName() + " == " + seq.state(n)}
+		if done := seq.stepDone(n); done != "" {
+			parts = append(parts, done)
+		}
+		if reaches != "1" {
+			parts = append(parts, reaches)
+		}
+		if len(parts) == 1 {
+			conds = append(conds, parts[0])
+		} else {
+			conds = append(conds, "("+strings.Join(parts, " && ")+")")
+		}
+	}
+	return conds
+}
+
+func sameClock(a, b AST.ClockDecl) bool {
+	return a.Name.Obj == b.Name.Obj && a.Neg == b.Neg
+}
+
+func (gen *generator) emitClockEdge(clk AST.ClockDecl) string {
+	if clk.Neg {
+		return "negedge " + gen.signalName(clk.Name)
+	}
+	return "posedge " + gen.signalName(clk.Name)
+}
+
+func (gen *generator) emitSequence(stmt *AST.SequenceStmt, ident int) {
+	root := gen.buildSequence(stmt, stmt.Start == nil)
+	seqs := root.flatten()
+
+	gen.writeToFile(fmt.Sprintf("%s// Sequence at %d:%d\n", Indent(ident), stmt.StartPos[0], stmt.StartPos[1]))
+
+	//State registers
+	for _, seq := range seqs {
+		str := Indent(ident) + "reg"
+		if seq.stateWidth() > 1 {
+			str += fmt.Sprintf(" [%d:0]", seq.stateWidth()-1)
+		}
+		init := seq.waiting()
+		if seq.Active {
+			init = 0
+		}
+		str += " " + seq.stateName() + " = " + seq.state(init) + ";\n"
+		gen.writeToFile(str)
+
+		if width := seq.counterWidth(); width > 0 {
+			str = Indent(ident) + "reg"
+			if width > 1 {
+				str += fmt.Sprintf(" [%d:0]", width-1)
+			}
+			count := 0
+			if seq.Active && seq.cycles(0) > 1 {
+				count = seq.cycles(0) - 1
+			}
+			str += " " + seq.counterName() + " = " + seq.count(count) + ";\n"
+			gen.writeToFile(str)
+		}
+
+		for depth := 0; depth < seq.loopDepth(); depth++ {
+			if width := seq.loopCounterWidth(depth); width > 0 {
+				str = Indent(ident) + "reg"
+				if width > 1 {
+					str += fmt.Sprintf(" [%d:0]", width-1)
+				}
+				str += " " + seq.loopCounter(depth) + " = " + seq.loopCount(depth, 0) + ";\n"
+				gen.writeToFile(str)
+			}
+		}
+	}
+
+	gen.writeToFile(Indent(ident) + "always @(" + gen.emitClockEdge(root.Clock) + ")\n")
+	gen.writeToFile(Indent(ident) + "begin\n")
+	gen.emitSequenceStates(root, ident+1)
+	gen.writeToFile(Indent(ident) + "end\n")
+
+	gen.emitSequenceStatus(root, nil, ident)
+}
+
+// Busy is set in any state but idle, and done on the clock the sequence finishes without being aborted.
+// aborts are the abort conditions of the sequences around it, which stop it as well
+func (gen *generator) emitSequenceStatus(seq *sequence, aborts []string, ident int) {
+	if seq.Stmt.Abort != nil {
+		aborts = append(append([]string{}, aborts...), seq.aborting())
+	}
+
+	if busy := seq.Stmt.Busy; busy != nil {
+		gen.writeToFile(Indent(ident) + "assign " + gen.signalName(*busy) + " = " + seq.stateName() + " < " + seq.state(seq.idle()) + ";\n")
+	}
+	if done := seq.Stmt.Done; done != nil {
+		conds := seq.completing(seq.Cleanup)
+		str := strings.Join(conds, " || ")
+		switch {
+		case len(conds) == 0:
+			str = "1'b0"
+		case len(aborts) > 0:
+			if len(conds) > 1 {
+				str = "(" + str + ")"
+			}
+			for _, abort := range aborts {
+				str += " && !(" + abort + ")"
+			}
+		}
+		gen.writeToFile(Indent(ident) + "assign " + gen.signalName(*done) + " = " + str + ";\n")
+	}
+
+	for _, child := range seq.Children {
+		gen.emitSequenceStatus(child, aborts, ident)
+	}
+}
+
+// Writes the states of the sequence, along with those of the sequences nested within it.
+// Children are written first so that a parent restarting a child takes priority,
+// and within the abort of their parent so that none of their steps apply when it aborts
+func (gen *generator) emitSequenceStates(seq *sequence, ident int) {
+	if seq.Stmt.Abort != nil {
+		gen.emitSequenceAbort(seq, ident)
+		gen.writeToFile(Indent(ident) + "else\n")
+		gen.writeToFile(Indent(ident) + "begin\n")
+		ident++
+	}
+
+	for _, child := range seq.Children {
+		gen.emitSequenceStates(child, ident)
+	}
+
+	gen.writeToFile(Indent(ident) + "case (" + seq.stateName() + ")\n")
+
+	for n, step := range seq.Steps {
+		gen.writeToFile(Indent(ident+1) + seq.state(n) + ":\n")
+		gen.writeToFile(Indent(ident+1) + "begin\n")
+
+		for _, stmt := range step.Stmts {
+			gen.emitProcedural(stmt, ident+2)
+		}
+
+		if done := seq.stepDone(n); done != "" {
+			gen.writeToFile(Indent(ident+2) + "if (" + done + ")\n")
+			gen.writeToFile(Indent(ident+2) + "begin\n")
+			gen.emitTransition(seq, seq.after(n), ident+3)
+			gen.writeToFile(Indent(ident+2) + "end\n")
+			//Counts down the clocks left in the wait
+			if seq.cycles(n) > 1 {
+				gen.writeToFile(Indent(ident+2) + "else\n")
+				gen.writeToFile(Indent(ident+2) + "begin\n")
+				gen.writeToFile(Indent(ident+3) + seq.counterName() + " <= " + seq.counterName() + " - 1'd1;\n")
+				gen.writeToFile(Indent(ident+2) + "end\n")
+			}
+		} else {
+			gen.emitTransition(seq, seq.after(n), ident+2)
+		}
+
+		gen.writeToFile(Indent(ident+1) + "end\n")
+	}
+
+	if start := seq.Stmt.Start; start != nil {
+		gen.writeToFile(Indent(ident+1) + seq.state(seq.waiting()) + ":\n")
+		gen.writeToFile(Indent(ident+1) + "begin\n")
+		gen.writeToFile(Indent(ident+2) + "if (" + gen.emitCondition(start) + ")\n")
+		gen.writeToFile(Indent(ident+2) + "begin\n")
+		gen.emitTransition(seq, seq.start(), ident+3)
+		gen.writeToFile(Indent(ident+2) + "end\n")
+		gen.writeToFile(Indent(ident+1) + "end\n")
+	}
+
+	gen.writeToFile(Indent(ident) + "endcase\n")
+
+	if seq.Stmt.Abort != nil {
+		gen.writeToFile(Indent(ident-1) + "end\n")
+	}
+}
+
+// Condition for the sequence to abort on this clock, which it can do from any step before the cleanup
+func (seq *sequence) aborting() string {
+	return "(" + seq.emitCondition(seq.Stmt.Abort) + ") && " + seq.stateName() + " < " + seq.state(seq.Cleanup)
+}
+
+// Stops the sequences nested within it and goes to the cleanup
+func (gen *generator) emitSequenceAbort(seq *sequence, ident int) {
+	gen.writeToFile(Indent(ident) + "if (" + seq.aborting() + ")\n")
+	gen.writeToFile(Indent(ident) + "begin\n")
+
+	nested := seq.flatten()
+	for _, child := range nested[:len(nested)-1] {
+		gen.writeToFile(Indent(ident+1) + child.stateName() + " <= " + child.state(child.idle()) + ";\n")
+	}
+	gen.emitTransition(seq, seq.enter(seq.Cleanup, seq.loopsStarting(seq.Cleanup, 0)), ident+1)
+
+	gen.writeToFile(Indent(ident) + "end\n")
+}
+
+// Enters a step, starting any sequences nested within it and loading the counter of a wait
+func (gen *generator) emitSequenceEnter(seq *sequence, n int, ident int) {
+	gen.writeToFile(Indent(ident) + seq.stateName() + " <= " + seq.state(n) + ";\n")
+	if n >= len(seq.Steps) {
+		return
+	}
+	if cycles := seq.cycles(n); cycles > 1 {
+		gen.writeToFile(Indent(ident) + seq.counterName() + " <= " + seq.count(cycles-1) + ";\n")
+	}
+	for _, child := range seq.Steps[n].Children {
+		gen.emitTransition(child, child.start(), ident)
+	}
+}
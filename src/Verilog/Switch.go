@@ -0,0 +1,83 @@
+package verilog
+
+import (
+	"strconv"
+	"strings"
+
+	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
+)
+
+// Switches become a case statement, or casez when a value has don't care bits.
+// The arms are qualified from what the semantic pass found:
+//
+//	unique      every value has exactly one arm, so the arms can be matched in parallel
+//	priority    every value has an arm, but some have several so the first one is taken
+//	neither     some values have no arm, in which case nothing is assigned
+//
+// A default arm is always written last, as verilog only takes it when nothing else matches
+
+func (gen *generator) emitSwitch(sw *AST.SwitchStmt, ident int) {
+	keyword := "case"
+	for _, arm := range sw.Cases {
+		for _, value := range arm.Values {
+			if lit, ok := value.(*AST.Literal); ok && lit.Mask != nil {
+				keyword = "casez"
+			}
+		}
+	}
+
+	if sw.Complete && sw.Overlapping {
+		keyword = "priority " + keyword
+	} else if sw.Complete {
+		keyword = "unique " + keyword
+	}
+
+	width := sw.X.ResultWidth()
+	gen.writeToFile(Indent(ident) + keyword + " (" + gen.emitCondition(sw.X) + ")\n")
+
+	var def *AST.CaseClause
+	for i, arm := range sw.Cases {
+		if arm.IsDefault() {
+			def = &sw.Cases[i]
+			continue
+		}
+		var values []string
+		for _, value := range arm.Values {
+			lit, ok := value.(*AST.Literal)
+			if !ok {
+				gen.displayError(D.UnsupportedConstruct, value.GetPos(), "Switch case value must be a literal")
+			}
+			values = append(values, emitCaseValue(lit, width))
+		}
+		gen.writeToFile(Indent(ident+1) + strings.Join(values, ", ") + ":\n")
+		gen.emitProceduralBlock(arm.Body, ident+1)
+	}
+	if def != nil {
+		gen.writeToFile(Indent(ident+1) + "default:\n")
+		gen.emitProceduralBlock(def.Body, ident+1)
+	}
+
+	gen.writeToFile(Indent(ident) + "endcase\n")
+}
+
+// Values with don't care bits are written out in binary, one character per bit of the selector
+func emitCaseValue(lit *AST.Literal, width int) string {
+	if lit.Mask == nil {
+		return emitLiteral(lit)
+	}
+
+	var str strings.Builder
+	str.WriteString(strconv.Itoa(width) + "'b")
+	for bit := width - 1; bit >= 0; bit-- {
+		switch {
+		case lit.Mask.Bit(bit) == 1:
+			str.WriteByte('?')
+		case lit.Value.Bit(bit) == 1:
+			str.WriteByte('1')
+		default:
+			str.WriteByte('0')
+		}
+	}
+	return str.String()
+}
@@ -2,44 +2,79 @@ package verilog
 
 import (
 	"fmt"
+	"math/big"
 	"os"
 	"reflect"
-	"runtime"
 	"strings"
 
 	AST "github.com/ConnerTenn/Project-Chrono/AST"
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
 )
 
 func Indent(level int) string {
 	return strings.Repeat("\t", level)
 }
 
-func displayError(msg string) {
-	_, fn, line, _ := runtime.Caller(1)
-	fmt.Printf("Error: "+msg+"\n -- at  %s:%d\n", fn, line)
-	os.Exit(-1)
+// The state of generating a single file
+type generator struct {
+	output      strings.Builder // generated verilog for the file
+	diagnostics []D.Diagnostic  // problems found while generating the file
+
+	signalNames   map[*AST.Object]string // names given to the renamed signals of the current module
+	usedNames     map[string]bool        // every name used in the current module
+	sequenceCount int                    // number of state registers generated in the current module
 }
 
-var outfile *os.File
+func (gen *generator) writeToFile(str string) {
+	gen.output.WriteString(str)
+}
 
-func createFile(filename string) {
-	outfile, _ = os.Create(filename)
+// Statements of a module, sorted by the kind of logic they produce
+type moduleBody struct {
+	*generator
+	Decls      []AST.SignalDecl  // Signals declared within the module
+	Assigns    []*AST.AssignStmt // Continuous assignments
+	Comb       []AST.Stmt        // Combinational logic that needs an always block
+	Domains    []*clockDomain    // Register assignments grouped by clock
+	Sequences  []*AST.SequenceStmt
+	Instances  []*AST.InstanceDecl
+	Procedural map[*AST.Object]bool // Signals driven from within an always block
+	Connected  map[*AST.Object]bool // Signals driven by the output of an instance
 }
-func writeToFile(str string) {
-	outfile.WriteString(str)
+
+// Register assignments that share a clock
+type clockDomain struct {
+	Clock AST.ClockDecl
+	Stmts []AST.Stmt
 }
-func closeFile() {
-	outfile.Close()
+
+func (body *moduleBody) domain(clk AST.ClockDecl) *clockDomain {
+	for _, dom := range body.Domains {
+		if sameClock(dom.Clock, clk) {
+			return dom
+		}
+	}
+	dom := &clockDomain{Clock: clk}
+	body.Domains = append(body.Domains, dom)
+	return dom
 }
 
-func emitModuleDecl(mod AST.ModuleDecl, scope *VariableScope) {
-	scope.EnterScope()
-	writeToFile("\nmodule " + mod.Name.Name + " (\n")
+func (gen *generator) emitModuleDecl(mod AST.ModuleDecl) {
+	gen.sequenceCount = 0
+	gen.resetNames()
+
+	for _, param := range mod.Params {
+		gen.nameSignal(param.Name)
+	}
+
+	body := &moduleBody{generator: gen, Procedural: map[*AST.Object]bool{}, Connected: map[*AST.Object]bool{}}
+	gen.collectBlock(mod.Block, body)
+	body.finalize()
+
+	gen.writeToFile("\nmodule " + mod.Name.Name + " (\n")
 
 	//Write parameters
 	for i, param := range mod.Params {
-		scope.DeclVariable(param.SignalDecl)
-
 		str := Indent(1)
 		//Dir
 		switch param.Dir {
@@ -51,79 +86,511 @@ func emitModuleDecl(mod AST.ModuleDecl, scope *VariableScope) {
 			str += "inout"
 		}
 		//wire/reg
-		if param.Clock != nil {
+		if body.isReg(param.SignalDecl) {
 			str += " reg"
 		}
-		//Bus width
-		if param.Width > 1 {
-			str += fmt.Sprintf(" [%d:0]", param.Width-1)
-		}
-		//Name
-		str += " " + param.Name.Name
+		str += body.emitSignal(param.SignalDecl)
 		if i < len(mod.Params)-1 {
 			str += ","
 		}
 		str += "\n"
-		writeToFile(str)
+		gen.writeToFile(str)
+	}
+	gen.writeToFile(");\n")
+
+	gen.emitModuleBody(body, 1)
+
+	gen.writeToFile("endmodule\n")
+}
+
+func (gen *generator) collectBlock(blk AST.BlockStmt, body *moduleBody) {
+	for _, stmt := range blk.StmtList {
+		gen.collectStatement(stmt, body)
 	}
-	writeToFile(");\n")
+}
+
+func (gen *generator) collectStatement(stmt AST.Stmt, body *moduleBody) {
+	switch obj := stmt.(type) {
+	case *AST.DeclStmt:
+		//Constants have already been replaced by their values
+		if decl, ok := obj.Decl.(*AST.SignalDecl); ok {
+			gen.nameSignal(decl.Name)
+			body.Decls = append(body.Decls, *decl)
+		}
+		if inst, ok := obj.Decl.(*AST.InstanceDecl); ok {
+			gen.nameSignal(inst.Name)
+			body.Instances = append(body.Instances, inst)
+			for _, conn := range inst.Conns {
+				gen.checkSelectExpr(conn.Value)
+				if isOutput(conn) {
+					body.Connected[gen.target(conn.Value).Obj] = true
+				}
+			}
+		}
+
+	case *AST.BlockStmt:
+		//Outside of a sequence, blocks are only a scope
+		gen.collectBlock(*obj, body)
+
+	case *AST.SequenceStmt:
+		gen.checkSelectStmt(obj)
+		body.Sequences = append(body.Sequences, obj)
+
+	case *AST.AssignStmt, *AST.IfStmt, *AST.SwitchStmt:
+		gen.checkSelectStmt(stmt)
 
-	emitBlock(mod.Block, 0, false, scope)
+		comb := gen.filterStmt(stmt, func(asmt *AST.AssignStmt) bool { return asmt.Op == AST.Asmt })
+		if comb != nil {
+			body.Comb = append(body.Comb, comb)
+		}
 
-	writeToFile("endmodule\n")
-	scope.ExitScope()
+		//Split register assignments out by the clock of the signal being assigned
+		for _, asmt := range assignments(stmt) {
+			if asmt.Op == AST.AsmtReg {
+				body.domain(gen.signalClock(asmt))
+			}
+		}
+		for _, dom := range body.Domains {
+			reg := gen.filterStmt(stmt, func(asmt *AST.AssignStmt) bool {
+				if asmt.Op != AST.AsmtReg {
+					return false
+				}
+				return sameClock(gen.signalClock(asmt), dom.Clock)
+			})
+			if reg != nil {
+				dom.Stmts = append(dom.Stmts, reg)
+			}
+		}
+
+	default:
+		gen.displayError(D.UnsupportedConstruct, stmt.GetPos(), "Unexpected statement in module: %v", reflect.TypeOf(stmt))
+	}
 }
 
-func emitBlock(blk AST.BlockStmt, ident int, surround bool, scope *VariableScope) {
-	scope.EnterScope()
-	if surround {
-		writeToFile(Indent(ident) + "begin\n")
+// The clock of the signal being assigned by a register assignment
+func (gen *generator) signalClock(asmt *AST.AssignStmt) AST.ClockDecl {
+	name := gen.target(asmt.LHS)
+	decl := gen.signalDecl(name)
+	if decl.Clock == nil {
+		gen.displayError(D.MissingClock, asmt.Pos, "Register assignment to %s, which has no clock", name.Name)
 	}
+	return *decl.Clock
+}
+
+// The signal written to by an assignment
+func (gen *generator) target(lhs AST.Expr) *AST.Ident {
+	switch obj := lhs.(type) {
+	case *AST.Ident:
+		return obj
+	case *AST.IndexExpr:
+		return gen.target(obj.X)
+	case *AST.SliceExpr:
+		return gen.target(obj.X)
+	}
+	gen.displayError(D.UnsupportedConstruct, lhs.GetPos(), "Unexpected assignment target: %v", reflect.TypeOf(lhs))
+	return nil
+}
 
-	//Write each statement
+// Every assignment made within a statement
+func assignments(stmt AST.Stmt) []*AST.AssignStmt {
+	var list []*AST.AssignStmt
+	switch obj := stmt.(type) {
+	case *AST.AssignStmt:
+		list = append(list, obj)
+	case *AST.IfStmt:
+		list = append(list, assignments(obj.Body)...)
+		if obj.Else != nil {
+			list = append(list, assignments(obj.Else)...)
+		}
+	case *AST.SwitchStmt:
+		for _, arm := range obj.Cases {
+			list = append(list, assignments(arm.Body)...)
+		}
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			list = append(list, assignments(inner)...)
+		}
+	case *AST.SequenceStmt:
+		list = append(list, assignments(obj.Inner)...)
+		if obj.Cleanup != nil {
+			list = append(list, assignments(obj.Cleanup)...)
+		}
+	}
+	return list
+}
+
+// Copies a statement, keeping only the assignments accepted by keep.
+// Returns nil if nothing is left
+func (gen *generator) filterStmt(stmt AST.Stmt, keep func(*AST.AssignStmt) bool) AST.Stmt {
+	switch obj := stmt.(type) {
+	case *AST.AssignStmt:
+		if keep(obj) {
+			return obj
+		}
+
+	case *AST.BlockStmt:
+		blk := AST.BlockStmt{StartPos: obj.StartPos, EndPos: obj.EndPos}
+		for _, inner := range obj.StmtList {
+			if filtered := gen.filterStmt(inner, keep); filtered != nil {
+				blk.StmtList = append(blk.StmtList, filtered)
+			}
+		}
+		if len(blk.StmtList) > 0 {
+			return &blk
+		}
+
+	case *AST.IfStmt:
+		body := gen.filterStmt(obj.Body, keep)
+		var els AST.Stmt
+		if obj.Else != nil {
+			els = gen.filterStmt(obj.Else, keep)
+		}
+		if body == nil && els == nil {
+			return nil
+		}
+		if body == nil {
+			body = &AST.BlockStmt{StartPos: obj.Body.GetPos()}
+		}
+		return &AST.IfStmt{Pos: obj.Pos, Cond: obj.Cond, Body: body, Else: els}
+
+	case *AST.SwitchStmt:
+		//Arms left empty are kept, so that the rest still match the same values
+		sw := *obj
+		sw.Cases = nil
+		empty := true
+		for _, arm := range obj.Cases {
+			body := gen.filterStmt(arm.Body, keep)
+			if body == nil {
+				body = &AST.BlockStmt{StartPos: arm.Body.GetPos()}
+			} else {
+				empty = false
+			}
+			sw.Cases = append(sw.Cases, AST.CaseClause{Pos: arm.Pos, Values: arm.Values, Body: body})
+		}
+		if !empty {
+			return &sw
+		}
+
+	case *AST.SequenceStmt:
+		gen.displayError(D.UnsupportedConstruct, obj.StartPos, "Sequences cannot be conditional")
+	}
+	return nil
+}
+
+// Signals driven only by a single unconditional assignment become continuous
+// assignments. Everything else is placed into an always block
+func (body *moduleBody) finalize() {
+	drivers := map[*AST.Object]int{}
+	for _, stmt := range body.Comb {
+		for _, asmt := range assignments(stmt) {
+			drivers[body.target(asmt.LHS).Obj]++
+		}
+	}
+
+	var comb []AST.Stmt
+	for _, stmt := range body.Comb {
+		if asmt, ok := stmt.(*AST.AssignStmt); ok && drivers[body.target(asmt.LHS).Obj] == 1 {
+			body.Assigns = append(body.Assigns, asmt)
+			continue
+		}
+		comb = append(comb, stmt)
+	}
+	body.Comb = comb
+
+	for _, stmt := range body.Comb {
+		for _, asmt := range assignments(stmt) {
+			body.Procedural[body.target(asmt.LHS).Obj] = true
+		}
+	}
+	for _, dom := range body.Domains {
+		for _, stmt := range dom.Stmts {
+			for _, asmt := range assignments(stmt) {
+				body.Procedural[body.target(asmt.LHS).Obj] = true
+			}
+		}
+	}
+	for _, seq := range body.Sequences {
+		for _, asmt := range assignments(seq) {
+			body.Procedural[body.target(asmt.LHS).Obj] = true
+		}
+	}
+}
+
+// Signals driven by an instance are wires, even when they hold the value of a register within it
+func (body *moduleBody) isReg(decl AST.SignalDecl) bool {
+	if body.Connected[decl.Name.Obj] {
+		return false
+	}
+	return decl.Clock != nil || body.Procedural[decl.Name.Obj]
+}
+
+// Width, name and initial value of a signal
+func (body *moduleBody) emitSignal(decl AST.SignalDecl) string {
 	str := ""
-	for _, stmt := range blk.StmtList {
-		emitStatement(stmt, ident+1, scope)
+	//Bus width
+	if decl.Width > 1 {
+		str += fmt.Sprintf(" [%d:0]", decl.Width-1)
+	}
+	//Name
+	str += " " + body.signalName(decl.Name)
+	if decl.Init != nil {
+		str += " = " + body.emitExpr(decl.Init)
+	}
+	return str
+}
+
+func (gen *generator) emitModuleBody(body *moduleBody, ident int) {
+	for _, decl := range body.Decls {
+		str := "wire"
+		if body.isReg(decl) {
+			str = "reg"
+		}
+		gen.writeToFile(Indent(ident) + str + body.emitSignal(decl) + ";\n")
+	}
+
+	for _, inst := range body.Instances {
+		gen.emitInstance(inst, ident)
 	}
-	writeToFile(str)
 
-	if surround {
-		writeToFile(Indent(ident) + "end\n")
+	for _, asmt := range body.Assigns {
+		gen.writeToFile(Indent(ident) + "assign " + gen.emitExpr(asmt.LHS) + " = " + gen.emitExpr(asmt.RHS) + ";\n")
+	}
+
+	if len(body.Comb) > 0 {
+		gen.writeToFile(Indent(ident) + "always @(*)\n")
+		gen.writeToFile(Indent(ident) + "begin\n")
+		for _, stmt := range body.Comb {
+			gen.emitProcedural(stmt, ident+1)
+		}
+		gen.writeToFile(Indent(ident) + "end\n")
+	}
+
+	for _, dom := range body.Domains {
+		gen.writeToFile(Indent(ident) + "always @(" + gen.emitClockEdge(dom.Clock) + ")\n")
+		gen.writeToFile(Indent(ident) + "begin\n")
+		for _, stmt := range dom.Stmts {
+			gen.emitProcedural(stmt, ident+1)
+		}
+		gen.writeToFile(Indent(ident) + "end\n")
+	}
+
+	for _, seq := range body.Sequences {
+		gen.emitSequence(seq, ident)
 	}
-	scope.ExitScope()
 }
 
-func emitStatement(stmt AST.Stmt, ident int, scope *VariableScope) {
+// Writes a statement that lives inside of an always block
+func (gen *generator) emitProcedural(stmt AST.Stmt, ident int) {
 	switch obj := stmt.(type) {
-	case *AST.DeclStmt:
-		scope.DeclVariable(*(obj.Decl.(*AST.SignalDecl)))
+	case *AST.AssignStmt:
+		op := " = "
+		if obj.Op == AST.AsmtReg {
+			op = " <= "
+		}
+		gen.writeToFile(Indent(ident) + gen.emitExpr(obj.LHS) + op + gen.emitExpr(obj.RHS) + ";\n")
+
+	case *AST.IfStmt:
+		gen.writeToFile(Indent(ident) + "if (" + gen.emitCondition(obj.Cond) + ")\n")
+		gen.emitProceduralBlock(obj.Body, ident)
+
+		//Chain else ifs together instead of nesting them
+		for obj.Else != nil {
+			elif, ok := obj.Else.(*AST.IfStmt)
+			if !ok {
+				gen.writeToFile(Indent(ident) + "else\n")
+				gen.emitProceduralBlock(obj.Else, ident)
+				break
+			}
+			gen.writeToFile(Indent(ident) + "else if (" + gen.emitCondition(elif.Cond) + ")\n")
+			gen.emitProceduralBlock(elif.Body, ident)
+			obj = elif
+		}
+
+	case *AST.SwitchStmt:
+		gen.emitSwitch(obj, ident)
+
+	case *AST.BlockStmt:
+		for _, inner := range obj.StmtList {
+			gen.emitProcedural(inner, ident)
+		}
+
+	default:
+		gen.displayError(D.UnsupportedConstruct, stmt.GetPos(), "Unexpected statement in always block: %v", reflect.TypeOf(stmt))
+	}
+}
+
+func (gen *generator) emitProceduralBlock(stmt AST.Stmt, ident int) {
+	gen.writeToFile(Indent(ident) + "begin\n")
+	gen.emitProcedural(stmt, ident+1)
+	gen.writeToFile(Indent(ident) + "end\n")
+}
+
+// Verilog operators for each operation.
+// Arithmetic right shifts are handled separately since they need to be done on a signed operand
+var verilogOperations = map[AST.Operation]string{
+	AST.Add:         "+",
+	AST.Sub:         "-",
+	AST.Multi:       "*",
+	AST.Div:         "/",
+	AST.Mod:         "%",
+	AST.LShift:      "<<",
+	AST.RShift:      ">>",
+	AST.ArithLShift: "<<<",
+	AST.ArithRShift: ">>>",
+	AST.Equals:      "==",
+	AST.NotEquals:   "!=",
+	AST.Less:        "<",
+	AST.LessEq:      "<=",
+	AST.Greater:     ">",
+	AST.GreaterEq:   ">=",
+	AST.BitAnd:      "&",
+	AST.BitOr:       "|",
+	AST.BitXor:      "^",
+	AST.LogicAnd:    "&&",
+	AST.LogicOr:     "||",
+	AST.Negate:      "-",
+	AST.Not:         "!",
+	AST.BitNot:      "~",
+	AST.ReduceAnd:   "&",
+	AST.ReduceOr:    "|",
+	AST.ReduceXor:   "^",
+}
+
+func (gen *generator) emitOperation(op AST.Operation, pos [2]int) string {
+	if str, ok := verilogOperations[op]; ok {
+		return str
+	}
+	gen.displayError(D.UnsupportedConstruct, pos, "Operation has no verilog equivalent: %v", op)
+	return ""
+}
+
+func (gen *generator) emitExpr(expr AST.Expr) string {
+	switch obj := expr.(type) {
+	case *AST.Ident:
+		return gen.signalName(*obj)
+	case *AST.Literal:
+		return emitLiteral(obj)
+	case *AST.ParenExpr:
+		return "(" + gen.emitExpr(obj.X) + ")"
+	case *AST.UnaryExpr:
+		return gen.emitOperation(obj.Op, obj.Pos) + gen.emitOperand(obj.X)
+	case *AST.MathExpr:
+		//Verilog only sign extends when the shifted value is signed, and an unsigned operand anywhere
+		//in the surrounding expression would make it unsigned again. The arguments of a function
+		//are sized on their own, so the shift is done within one and handed back unsigned
+		if obj.Op == AST.ArithRShift {
+			return "$unsigned($signed(" + gen.emitExpr(obj.LHS) + ") >>> " + gen.emitOperand(obj.RHS) + ")"
+		}
+		return gen.emitOperand(obj.LHS) + " " + gen.emitOperation(obj.Op, obj.Pos) + " " + gen.emitOperand(obj.RHS)
+	case *AST.IndexExpr:
+		return gen.emitSelected(obj.X) + "[" + gen.emitExpr(obj.Index) + "]"
+	case *AST.SliceExpr:
+		if obj.Kind == AST.SliceRange {
+			return gen.emitSelected(obj.X) + "[" + gen.emitExpr(obj.Left) + ":" + gen.emitExpr(obj.Right) + "]"
+		}
+		return gen.emitSelected(obj.X) + gen.sliceText(obj)
+	case *AST.ConcatExpr:
+		var parts []string
+		for _, part := range obj.Parts {
+			parts = append(parts, gen.emitExpr(part))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case *AST.ReplicateExpr:
+		inner := gen.emitExpr(obj.X)
+		if _, ok := obj.X.(*AST.ConcatExpr); !ok {
+			inner = "{" + inner + "}"
+		}
+		return "{" + gen.emitOperand(obj.Count) + inner + "}"
 	}
+	gen.displayError(D.UnsupportedConstruct, expr.GetPos(), "Unexpected expression: %v", reflect.TypeOf(expr))
+	return ""
+}
+
+var literalBases = map[AST.LiteralKind]struct {
+	Char string
+	Base int
+}{
+	AST.Decimal: {"d", 10},
+	AST.Hex:     {"h", 16},
+	AST.Binary:  {"b", 2},
+	AST.Octal:   {"o", 8},
 }
 
-func GenerateVerilog(ast []AST.AST) {
-	createFile("generated.sv")
-	defer closeFile()
+// Literals keep the base they were written in.
+// Unsized verilog literals are only 32 bits, so anything wider is given a size
+func emitLiteral(lit *AST.Literal) string {
+	//Folding can leave a negative value, but verilog literals can't hold a sign
+	if lit.Value.Sign() < 0 {
+		magnitude := *lit
+		magnitude.Value = new(big.Int).Neg(lit.Value)
+		return "-(" + emitLiteral(&magnitude) + ")"
+	}
+
+	base := literalBases[lit.Kind]
+	digits := lit.Value.Text(base.Base)
 
-	for _, elem := range ast {
+	width := lit.Width
+	if width == 0 {
+		if lit.Value.BitLen() <= 32 {
+			if lit.Kind == AST.Decimal {
+				return digits
+			}
+			return "'" + base.Char + digits
+		}
+		width = lit.Value.BitLen()
+	}
+
+	return fmt.Sprintf("%d'%s%s", width, base.Char, digits)
+}
+
+// Verilog can only select bits from a signal, not from the result of an expression
+func (gen *generator) emitSelected(x AST.Expr) string {
+	if _, ok := x.(*AST.Ident); !ok {
+		gen.displayError(D.UnsupportedConstruct, x.GetPos(), "Only signals can be indexed or sliced")
+	}
+	return gen.emitExpr(x)
+}
+
+// Nested operations are always bracketed so the verilog precedence rules never come into play
+func (gen *generator) emitOperand(expr AST.Expr) string {
+	switch expr.(type) {
+	case *AST.MathExpr, *AST.UnaryExpr:
+		return "(" + gen.emitExpr(expr) + ")"
+	}
+	return gen.emitExpr(expr)
+}
+
+// Conditions are already bracketed by the statement they are in
+func (gen *generator) emitCondition(expr AST.Expr) string {
+	if paren, ok := expr.(*AST.ParenExpr); ok {
+		return gen.emitCondition(paren.X)
+	}
+	return gen.emitExpr(expr)
+}
+
+func GenerateVerilog(ast []AST.AST) []D.Diagnostic {
+	gen := &generator{}
+
+	for _, elem := range dependencyOrder(ast) {
 		switch obj := elem.(type) {
 		case AST.ModuleDecl:
-			fmt.Println("BlockStmt")
-			emitModuleDecl(obj, &VariableScope{})
+			gen.emitModule(obj)
+		case *AST.ValueDecl:
+			//Folded into everywhere it is used
 		default:
-			displayError("Unexpected AST element: " + fmt.Sprint(reflect.TypeOf(elem)))
-		}
-	}
-	// 	filescope := ast.(*AST.BlockStmt)
-
-	// for stmt := range filescope.StmtList
-	// 	switch obj := filescope.(type) {
-	// 	case AST.Expr:
-	// 		fmt.Println("Expr", obj.(AST.Expr))
-	// 	case AST.Stmt:
-	// 		fmt.Println("Stmt")
-	// 	case *AST.BlockStmt:
-	// 		fmt.Println("BlockStmt")
-	// 	case AST.Decl:
-	// 		fmt.Println("Decl")
-	// 	}
+			gen.diagnostics = append(gen.diagnostics, D.Errorf(D.UnsupportedConstruct, elem.GetPos(), elem.GetPos(),
+				"Unexpected AST element: %v", reflect.TypeOf(elem)))
+		}
+	}
+
+	//Only produce an output if the whole file could be generated
+	if !D.HasErrors(gen.diagnostics) {
+		err := os.WriteFile("generated.sv", []byte(gen.output.String()), 0644)
+		if err != nil {
+			gen.diagnostics = append(gen.diagnostics, D.Errorf(D.WriteFailure, [2]int{}, [2]int{},
+				"Could not write generated.sv: %v", err))
+		}
+	}
+
+	return gen.diagnostics
 }
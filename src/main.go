@@ -4,23 +4,41 @@ import (
 	"fmt"
 	"os"
 
+	D "github.com/ConnerTenn/Project-Chrono/Diagnostics"
 	L "github.com/ConnerTenn/Project-Chrono/Lexer"
 	P "github.com/ConnerTenn/Project-Chrono/Parser"
+	S "github.com/ConnerTenn/Project-Chrono/Semantic"
 	verilog "github.com/ConnerTenn/Project-Chrono/Verilog"
 )
 
 // todo: add type to hold CLI options, with description for help menu
 func ShowHelp() {
 	fmt.Print(`Usage:
-./Project-Chrono [-h] [<file>]
+./Project-Chrono [-h] [--cdc-report] [<file>]
     -h --help       Show the help menu.
                     This argument is optional and will cause the program to
                     exit immediately.
+    --cdc-report    Print every clock domain crossing in each module,
+                    for review.
 `)
 
 	os.Exit(-1)
 }
 
+// Prints the diagnostics and exits if any of them are errors
+func report(diags []D.Diagnostic, filename string) {
+	D.SetFile(diags, filename)
+	D.Sort(diags)
+
+	for _, diag := range diags {
+		fmt.Fprintln(os.Stderr, diag)
+	}
+
+	if D.HasErrors(diags) {
+		os.Exit(1)
+	}
+}
+
 func main() {
 	// parse CLI command
 	args := os.Args
@@ -30,28 +48,59 @@ func main() {
 		ShowHelp()
 	}
 
-	if args[1] == "-h" || args[1] == "--help" {
-		ShowHelp()
+	var filename string
+	cdcReport := false
+	for _, arg := range args[1:] {
+		switch arg {
+		case "-h", "--help":
+			ShowHelp()
+		case "--cdc-report":
+			cdcReport = true
+		default:
+			filename = arg
+		}
 	}
 
-	filename := args[1]
+	if filename == "" {
+		fmt.Println("Please specify a file for compiling.")
+		ShowHelp()
+	}
 
 	// for loop / switch over compiler options?
 
 	lex, err := L.NewLexer(filename)
 	if err != nil {
 		fmt.Println("Error:", err)
+		os.Exit(1)
 	}
 
 	go lex.Tokenizer()
 
 	// doing this sync for now
-	tree := P.Parse(&lex)
+	tree, diags := P.Parse(&lex)
+	report(append(lex.Diagnostics(), diags...), filename)
+
+	report(S.Resolve(tree), filename)
+	tree, diags = S.FoldConstants(tree)
+	report(diags, filename)
+	report(S.CheckWidths(tree), filename)
+	report(S.CheckSwitches(tree), filename)
+	report(S.CheckInstances(tree), filename)
+	report(S.CheckSequences(tree), filename)
+	report(S.CheckDrivers(tree), filename)
+	report(S.CheckLatches(tree), filename)
+	report(S.CheckNetlist(tree), filename)
+
+	cdc, diags := S.CheckCDC(tree)
+	report(diags, filename)
+	if cdcReport {
+		fmt.Print(cdc)
+	}
 
 	for _, elem := range tree {
 		fmt.Print(elem)
 		fmt.Println()
 	}
 
-	verilog.GenerateVerilog(tree)
+	report(verilog.GenerateVerilog(tree), filename)
 }